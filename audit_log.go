@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/nacen-dev/chirpy/internal/database"
+)
+
+// Audit log events.
+const (
+	auditLoginLocked     = "login.locked"
+	auditLoginUnlocked   = "login.unlocked"
+	auditUserSuspended   = "user.suspended"
+	auditUserUnsuspended = "user.unsuspended"
+)
+
+// audit records a security-relevant event. It is best effort: a failure is
+// logged but doesn't fail the request that caused the event.
+func (cfg *apiConfig) audit(ctx context.Context, event string, userId uuid.NullUUID, ipAddress string, details map[string]any) {
+	detailsJSON, err := json.Marshal(details)
+	if err != nil {
+		log.Printf("Unable to encode audit log details for %s: %s", event, err)
+		detailsJSON = []byte("{}")
+	}
+
+	err = cfg.db.CreateAuditLogEntry(ctx, database.CreateAuditLogEntryParams{
+		Event:     event,
+		UserID:    userId,
+		IpAddress: sql.NullString{String: ipAddress, Valid: ipAddress != ""},
+		Details:   detailsJSON,
+	})
+	if err != nil {
+		log.Printf("Unable to write audit log entry for %s: %s", event, err)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/nacen-dev/chirpy/internal/database"
+)
+
+// A hashtag starts at the beginning of the body or after a character that
+// can't be part of a word, so "a#b" and "&#39;" aren't tags.
+var hashtagPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&])#([\p{L}\p{N}_]{1,100})`)
+
+// extractHashtags returns the distinct, lowercased hashtags of a chirp body
+// in order of appearance. Purely numeric tags like #1 are ignored, and so are
+// profane ones, which would otherwise show up uncensored in tag feeds and
+// trending tags.
+func extractHashtags(body string) []string {
+	tags := []string{}
+	seen := map[string]struct{}{}
+	for _, match := range hashtagPattern.FindAllStringSubmatch(body, -1) {
+		tag := strings.ToLower(match[1])
+		if strings.IndexFunc(tag, func(r rune) bool { return !unicode.IsDigit(r) }) == -1 {
+			continue
+		}
+		if _, ok := profaneWords[tag]; ok {
+			continue
+		}
+		if _, ok := seen[tag]; ok {
+			continue
+		}
+		seen[tag] = struct{}{}
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// Mentions follow the same boundary rule as hashtags, which also keeps email
+// addresses from being read as mentions. The handle is matched greedily so a
+// handle that is too long isn't cut down to somebody else's.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&@])(@([A-Za-z0-9_]+))`)
+
+// mentionToken is an @handle in a chirp body. Start and End are rune offsets
+// of the whole token, "@" included, with End exclusive.
+type mentionToken struct {
+	Username string
+	Start    int
+	End      int
+}
+
+// extractMentions returns the well-formed @handles of a chirp body in order
+// of appearance. Whether a handle belongs to a user is decided when the
+// mentions are stored.
+func extractMentions(body string) []mentionToken {
+	mentions := []mentionToken{}
+	for _, match := range mentionPattern.FindAllStringSubmatchIndex(body, -1) {
+		tokenStart, tokenEnd := match[2], match[3]
+		username := body[match[4]:match[5]]
+		if len(username) > maxUsernameLength {
+			continue
+		}
+		start := utf8.RuneCountInString(body[:tokenStart])
+		mentions = append(mentions, mentionToken{
+			Username: username,
+			Start:    start,
+			End:      start + utf8.RuneCountInString(body[tokenStart:tokenEnd]),
+		})
+	}
+	return mentions
+}
+
+// indexChirpEntities (re)builds the hashtag and mention indexes for a chirp.
+// It is called after a chirp is created or edited.
+func (cfg *apiConfig) indexChirpEntities(ctx context.Context, chirp database.Chirp) error {
+	err := cfg.db.DeleteChirpTags(ctx, chirp.ID)
+	if err != nil {
+		return err
+	}
+	tags := extractHashtags(chirp.Body)
+	if len(tags) > 0 {
+		err = cfg.db.TagChirp(ctx, database.TagChirpParams{
+			Names:   tags,
+			ChirpID: chirp.ID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	err = cfg.db.DeleteChirpMentions(ctx, chirp.ID)
+	if err != nil {
+		return err
+	}
+	mentions := extractMentions(chirp.Body)
+	if len(mentions) == 0 {
+		return nil
+	}
+	params := database.MentionUsersParams{ChirpID: chirp.ID}
+	for _, mention := range mentions {
+		params.Usernames = append(params.Usernames, mention.Username)
+		params.StartOffsets = append(params.StartOffsets, int32(mention.Start))
+		params.EndOffsets = append(params.EndOffsets, int32(mention.End))
+	}
+	return cfg.db.MentionUsers(ctx, params)
+}
+
+// relocateMentions moves mention offsets from a chirp body to a rewritten
+// version of it, such as the profanity-filtered one. The rewrite leaves the
+// @handles themselves alone, so the n-th handle of one body is the n-th
+// handle of the other.
+func relocateMentions(body string, rewrittenBody string, mentions []ChirpMention) []ChirpMention {
+	tokenIndexes := map[int]int{}
+	for index, token := range extractMentions(body) {
+		tokenIndexes[token.Start] = index
+	}
+	rewrittenTokens := extractMentions(rewrittenBody)
+
+	relocated := []ChirpMention{}
+	for _, mention := range mentions {
+		index, ok := tokenIndexes[mention.Start]
+		if !ok || index >= len(rewrittenTokens) {
+			continue
+		}
+		mention.Start = rewrittenTokens[index].Start
+		mention.End = rewrittenTokens[index].End
+		relocated = append(relocated, mention)
+	}
+	return relocated
+}
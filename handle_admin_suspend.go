@@ -0,0 +1,80 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// handleSuspendUser suspends a user's account: they are signed out
+// everywhere and can't log in again until the suspension is lifted.
+func (cfg *apiConfig) handleSuspendUser(res http.ResponseWriter, req *http.Request) {
+	err := cfg.authenticateAdmin(req)
+	if err != nil {
+		respondWithError(res, http.StatusUnauthorized, "Invalid or missing api key", err)
+		return
+	}
+
+	userId, err := uuid.Parse(req.PathValue("userId"))
+	if err != nil {
+		respondWithError(res, http.StatusBadRequest, "Invalid user id", err)
+		return
+	}
+
+	user, err := cfg.db.SuspendUser(req.Context(), userId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(res, http.StatusNotFound, "Couldn't find user", err)
+			return
+		}
+		respondWithError(res, http.StatusInternalServerError, "Unable to suspend the user", err)
+		return
+	}
+
+	// The user is marked as suspended first, so they can't log in again in
+	// between. Suspending is idempotent, so a failure here can be retried.
+	err = cfg.signOutEverywhere(req.Context(), user.ID)
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to sign out the user's sessions", err)
+		return
+	}
+
+	_, ipAddress := clientInfo(req)
+	cfg.audit(req.Context(), auditUserSuspended, uuid.NullUUID{UUID: user.ID, Valid: true}, ipAddress, map[string]any{
+		"email": user.Email,
+	})
+	res.WriteHeader(http.StatusNoContent)
+}
+
+// handleUnsuspendUser lifts a suspension. The user has to log in again.
+func (cfg *apiConfig) handleUnsuspendUser(res http.ResponseWriter, req *http.Request) {
+	err := cfg.authenticateAdmin(req)
+	if err != nil {
+		respondWithError(res, http.StatusUnauthorized, "Invalid or missing api key", err)
+		return
+	}
+
+	userId, err := uuid.Parse(req.PathValue("userId"))
+	if err != nil {
+		respondWithError(res, http.StatusBadRequest, "Invalid user id", err)
+		return
+	}
+
+	user, err := cfg.db.UnsuspendUser(req.Context(), userId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(res, http.StatusNotFound, "Couldn't find user", err)
+			return
+		}
+		respondWithError(res, http.StatusInternalServerError, "Unable to lift the suspension", err)
+		return
+	}
+
+	_, ipAddress := clientInfo(req)
+	cfg.audit(req.Context(), auditUserUnsuspended, uuid.NullUUID{UUID: user.ID, Valid: true}, ipAddress, map[string]any{
+		"email": user.Email,
+	})
+	res.WriteHeader(http.StatusNoContent)
+}
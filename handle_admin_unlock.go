@@ -0,0 +1,46 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// handleUnlockUser lifts a login lockout on a user's account.
+func (cfg *apiConfig) handleUnlockUser(res http.ResponseWriter, req *http.Request) {
+	err := cfg.authenticateAdmin(req)
+	if err != nil {
+		respondWithError(res, http.StatusUnauthorized, "Invalid or missing api key", err)
+		return
+	}
+
+	userId, err := uuid.Parse(req.PathValue("userId"))
+	if err != nil {
+		respondWithError(res, http.StatusBadRequest, "Invalid user id", err)
+		return
+	}
+
+	user, err := cfg.db.GetUserById(req.Context(), userId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(res, http.StatusNotFound, "Couldn't find user", err)
+			return
+		}
+		respondWithError(res, http.StatusInternalServerError, "unable to retrieve the user", err)
+		return
+	}
+
+	err = cfg.loginThrottles.accounts.Reset(req.Context(), accountThrottleKey(user.Email))
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to unlock the user", err)
+		return
+	}
+
+	_, ipAddress := clientInfo(req)
+	cfg.audit(req.Context(), auditLoginUnlocked, uuid.NullUUID{UUID: user.ID, Valid: true}, ipAddress, map[string]any{
+		"email": user.Email,
+	})
+	res.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nacen-dev/chirpy/internal/database"
+)
+
+type WebhookEvent struct {
+	ID             uuid.UUID       `json:"id"`
+	Provider       string          `json:"provider"`
+	EventID        string          `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	ReceivedAt     time.Time       `json:"received_at"`
+	Status         string          `json:"status"`
+	Attempts       int32           `json:"attempts"`
+	ResponseStatus *int32          `json:"response_status"`
+	Error          *string         `json:"error"`
+	ProcessedAt    *time.Time      `json:"processed_at"`
+}
+
+func webhookEventFromDB(event database.WebhookEvent) WebhookEvent {
+	response := WebhookEvent{
+		ID:         event.ID,
+		Provider:   event.Provider,
+		EventID:    event.EventID,
+		EventType:  event.EventType,
+		Payload:    event.Payload,
+		ReceivedAt: event.ReceivedAt,
+		Status:     event.Status,
+		Attempts:   event.Attempts,
+		Error:      nullStringPtr(event.Error),
+	}
+	if event.ResponseStatus.Valid {
+		response.ResponseStatus = &event.ResponseStatus.Int32
+	}
+	if event.ProcessedAt.Valid {
+		response.ProcessedAt = &event.ProcessedAt.Time
+	}
+	return response
+}
+
+// handleGetWebhookEvents lists received webhook events, newest first,
+// optionally only those with the given ?status=.
+func (cfg *apiConfig) handleGetWebhookEvents(res http.ResponseWriter, req *http.Request) {
+	err := cfg.authenticateAdmin(req)
+	if err != nil {
+		respondWithError(res, http.StatusUnauthorized, "Invalid or missing api key", err)
+		return
+	}
+
+	status := sql.NullString{}
+	switch statusParam := req.URL.Query().Get("status"); statusParam {
+	case "":
+	case webhookStatusProcessing, webhookStatusProcessed, webhookStatusIgnored, webhookStatusFailed:
+		status = sql.NullString{String: statusParam, Valid: true}
+	default:
+		respondWithError(res, http.StatusBadRequest, "Invalid status", nil)
+		return
+	}
+
+	page, err := parsePageParams(req)
+	if err != nil {
+		respondWithError(res, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	events, err := cfg.db.GetWebhookEvents(req.Context(), database.GetWebhookEventsParams{
+		Status:          status,
+		HasCursor:       page.HasCursor,
+		CursorCreatedAt: page.Cursor.CreatedAt,
+		CursorID:        page.Cursor.ID,
+		PageLimit:       page.Limit + 1,
+	})
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to get webhook events", err)
+		return
+	}
+
+	if len(events) > int(page.Limit) {
+		events = events[:page.Limit]
+		last := events[len(events)-1]
+		setNextPageLink(res, req, pageCursor{CreatedAt: last.ReceivedAt, ID: last.ID})
+	}
+
+	response := []WebhookEvent{}
+	for _, event := range events {
+		response = append(response, webhookEventFromDB(event))
+	}
+	respondWithJSON(res, http.StatusOK, response)
+}
+
+// handleReplayWebhookEvent processes a failed event again, for example after
+// the problem that made it fail was fixed. An event that got stuck
+// processing, because the server crashed for example, can be replayed too
+// once its claim has run out.
+func (cfg *apiConfig) handleReplayWebhookEvent(res http.ResponseWriter, req *http.Request) {
+	err := cfg.authenticateAdmin(req)
+	if err != nil {
+		respondWithError(res, http.StatusUnauthorized, "Invalid or missing api key", err)
+		return
+	}
+
+	eventId, err := uuid.Parse(req.PathValue("eventId"))
+	if err != nil {
+		respondWithError(res, http.StatusBadRequest, "Invalid event id", err)
+		return
+	}
+
+	event, err := cfg.db.RetryWebhookEvent(req.Context(), eventId)
+	if errors.Is(err, sql.ErrNoRows) {
+		_, err = cfg.db.GetWebhookEventById(req.Context(), eventId)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(res, http.StatusNotFound, "Webhook event not found", err)
+			return
+		}
+		if err != nil {
+			respondWithError(res, http.StatusInternalServerError, "Unable to replay the event", err)
+			return
+		}
+		respondWithError(res, http.StatusConflict, "Only failed or abandoned events can be replayed", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to replay the event", err)
+		return
+	}
+
+	event, err = cfg.processWebhookEvent(req.Context(), event)
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to replay the event", err)
+		return
+	}
+	respondWithJSON(res, http.StatusOK, webhookEventFromDB(event))
+}
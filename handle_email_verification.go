@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nacen-dev/chirpy/internal/auth"
+	"github.com/nacen-dev/chirpy/internal/database"
+	"github.com/nacen-dev/chirpy/internal/mail"
+)
+
+const emailVerificationTokenLifetime = 24 * time.Hour
+
+// sendEmailVerification mails a verification link to email, which is either
+// the user's current address or the one they asked to change to. Only the
+// newest link for a user works.
+func (cfg *apiConfig) sendEmailVerification(ctx context.Context, userId uuid.UUID, email string) error {
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return err
+	}
+
+	err = cfg.db.CreateEmailVerificationToken(ctx, database.CreateEmailVerificationTokenParams{
+		UserID:          userId,
+		TokenHash:       auth.HashToken(token),
+		Email:           email,
+		LifetimeSeconds: emailVerificationTokenLifetime.Seconds(),
+	})
+	if err != nil {
+		return err
+	}
+
+	verifyURL := cfg.publicURL + "/api/verify-email?token=" + url.QueryEscape(token)
+	cfg.sendMailInBackground(mail.Message{
+		To:      email,
+		Subject: "Verify your email address for Chirpy",
+		Body: fmt.Sprintf("To confirm that this is your email address, open %s\n\n"+
+			"The link expires in %s. If you didn't sign up for Chirpy or change your email, you can ignore this email.\n",
+			verifyURL, emailVerificationTokenLifetime),
+	})
+	return nil
+}
+
+// canChirp reports whether the user may post. With REQUIRE_VERIFIED_EMAIL set
+// only users who verified their email address can.
+func (cfg *apiConfig) canChirp(ctx context.Context, userId uuid.UUID) (bool, error) {
+	if !cfg.requireVerifiedEmail {
+		return true, nil
+	}
+	user, err := cfg.db.GetUserById(ctx, userId)
+	if err != nil {
+		return false, err
+	}
+	return user.EmailVerifiedAt.Valid, nil
+}
+
+// handleVerifyEmail is where the link in a verification mail leads. For a
+// pending email change it is also the point where the new address replaces
+// the old one.
+func (cfg *apiConfig) handleVerifyEmail(res http.ResponseWriter, req *http.Request) {
+	token := req.URL.Query().Get("token")
+	if token == "" {
+		respondWithError(res, http.StatusBadRequest, "Missing token", nil)
+		return
+	}
+
+	user, err := cfg.db.VerifyEmailWithToken(req.Context(), auth.HashToken(token))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(res, http.StatusBadRequest, "Invalid or expired verification link", err)
+			return
+		}
+		if isUniqueViolation(err, "users_email_key") {
+			respondWithError(res, http.StatusConflict, "Email is already in use by another account", err)
+			return
+		}
+		respondWithError(res, http.StatusInternalServerError, "Unable to verify the email", err)
+		return
+	}
+
+	respondWithJSON(res, http.StatusOK, userFromDB(user))
+}
+
+// handleResendEmailVerification sends a new verification link for the
+// pending email change if there is one, and for the current address
+// otherwise.
+func (cfg *apiConfig) handleResendEmailVerification(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticatedUserID(req, scopeAccount)
+	if err != nil {
+		respondWithError(res, authErrorStatus(err), "Invalid or missing token", err)
+		return
+	}
+
+	user, err := cfg.db.GetUserById(req.Context(), userId)
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "unable to retrieve the user", err)
+		return
+	}
+
+	email := user.PendingEmail.String
+	if !user.PendingEmail.Valid {
+		if user.EmailVerifiedAt.Valid {
+			respondWithError(res, http.StatusConflict, "Email is already verified", nil)
+			return
+		}
+		email = user.Email
+	}
+
+	err = cfg.sendEmailVerification(req.Context(), user.ID, email)
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to send the verification email", err)
+		return
+	}
+	res.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nacen-dev/chirpy/internal/database"
+)
+
+type Follow struct {
+	UserID     uuid.UUID     `json:"user_id"`
+	FollowedAt time.Time     `json:"followed_at"`
+	User       PublicProfile `json:"user"`
+}
+
+// withProfiles fills in the public profiles of a page of follows with a
+// single query.
+func (cfg *apiConfig) withProfiles(ctx context.Context, follows []Follow) error {
+	if len(follows) == 0 {
+		return nil
+	}
+
+	userIds := make([]uuid.UUID, 0, len(follows))
+	for _, follow := range follows {
+		userIds = append(userIds, follow.UserID)
+	}
+	users, err := cfg.db.GetUsersByIds(ctx, userIds)
+	if err != nil {
+		return err
+	}
+	profiles := map[uuid.UUID]PublicProfile{}
+	for _, user := range users {
+		profiles[user.ID] = publicProfileFromDB(user)
+	}
+
+	for index := range follows {
+		follows[index].User = profiles[follows[index].UserID]
+	}
+	return nil
+}
+
+func (cfg *apiConfig) handleFollowUser(res http.ResponseWriter, req *http.Request) {
+	followeeId, err := uuid.Parse(req.PathValue("userId"))
+	if err != nil {
+		respondWithError(res, http.StatusBadRequest, "Invalid user id", err)
+		return
+	}
+
+	userIdFromJWT, err := cfg.authenticatedUserID(req, scopeProfileWrite)
+	if err != nil {
+		respondWithError(res, authErrorStatus(err), "Invalid or missing token", err)
+		return
+	}
+
+	if followeeId == userIdFromJWT {
+		respondWithError(res, http.StatusBadRequest, "You can't follow yourself", nil)
+		return
+	}
+
+	_, err = cfg.db.GetUserById(req.Context(), followeeId)
+	if err != nil {
+		respondWithError(res, http.StatusNotFound, "Couldn't find user", err)
+		return
+	}
+
+	err = cfg.db.FollowUser(req.Context(), database.FollowUserParams{
+		FollowerID: userIdFromJWT,
+		FolloweeID: followeeId,
+	})
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to follow the user", err)
+		return
+	}
+	res.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handleUnfollowUser(res http.ResponseWriter, req *http.Request) {
+	followeeId, err := uuid.Parse(req.PathValue("userId"))
+	if err != nil {
+		respondWithError(res, http.StatusBadRequest, "Invalid user id", err)
+		return
+	}
+
+	userIdFromJWT, err := cfg.authenticatedUserID(req, scopeProfileWrite)
+	if err != nil {
+		respondWithError(res, authErrorStatus(err), "Invalid or missing token", err)
+		return
+	}
+
+	err = cfg.db.UnfollowUser(req.Context(), database.UnfollowUserParams{
+		FollowerID: userIdFromJWT,
+		FolloweeID: followeeId,
+	})
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to unfollow the user", err)
+		return
+	}
+	res.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handleGetFollowers(res http.ResponseWriter, req *http.Request) {
+	userId, err := uuid.Parse(req.PathValue("userId"))
+	if err != nil {
+		respondWithError(res, http.StatusBadRequest, "Invalid user id", err)
+		return
+	}
+
+	page, err := parsePageParams(req)
+	if err != nil {
+		respondWithError(res, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	followers, err := cfg.db.GetFollowers(req.Context(), database.GetFollowersParams{
+		UserID:          userId,
+		HasCursor:       page.HasCursor,
+		CursorCreatedAt: page.Cursor.CreatedAt,
+		CursorID:        page.Cursor.ID,
+		PageLimit:       page.Limit + 1,
+	})
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to get followers", err)
+		return
+	}
+
+	if len(followers) > int(page.Limit) {
+		followers = followers[:page.Limit]
+		last := followers[len(followers)-1]
+		setNextPageLink(res, req, pageCursor{CreatedAt: last.CreatedAt, ID: last.UserID})
+	}
+
+	response := []Follow{}
+	for _, follower := range followers {
+		response = append(response, Follow{
+			UserID:     follower.UserID,
+			FollowedAt: follower.CreatedAt,
+		})
+	}
+	err = cfg.withProfiles(req.Context(), response)
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to get followers", err)
+		return
+	}
+	respondWithJSON(res, http.StatusOK, response)
+}
+
+func (cfg *apiConfig) handleGetFollowing(res http.ResponseWriter, req *http.Request) {
+	userId, err := uuid.Parse(req.PathValue("userId"))
+	if err != nil {
+		respondWithError(res, http.StatusBadRequest, "Invalid user id", err)
+		return
+	}
+
+	page, err := parsePageParams(req)
+	if err != nil {
+		respondWithError(res, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	following, err := cfg.db.GetFollowing(req.Context(), database.GetFollowingParams{
+		UserID:          userId,
+		HasCursor:       page.HasCursor,
+		CursorCreatedAt: page.Cursor.CreatedAt,
+		CursorID:        page.Cursor.ID,
+		PageLimit:       page.Limit + 1,
+	})
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to get followed users", err)
+		return
+	}
+
+	if len(following) > int(page.Limit) {
+		following = following[:page.Limit]
+		last := following[len(following)-1]
+		setNextPageLink(res, req, pageCursor{CreatedAt: last.CreatedAt, ID: last.UserID})
+	}
+
+	response := []Follow{}
+	for _, followee := range following {
+		response = append(response, Follow{
+			UserID:     followee.UserID,
+			FollowedAt: followee.CreatedAt,
+		})
+	}
+	err = cfg.withProfiles(req.Context(), response)
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to get followed users", err)
+		return
+	}
+	respondWithJSON(res, http.StatusOK, response)
+}
+
+func (cfg *apiConfig) handleGetTimeline(res http.ResponseWriter, req *http.Request) {
+	userIdFromJWT, err := cfg.authenticatedUserID(req, scopeChirpsRead)
+	if err != nil {
+		respondWithError(res, authErrorStatus(err), "Invalid or missing token", err)
+		return
+	}
+
+	page, err := parsePageParams(req)
+	if err != nil {
+		respondWithError(res, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	chirps, err := cfg.db.GetTimeline(req.Context(), database.GetTimelineParams{
+		UserID:          userIdFromJWT,
+		HasCursor:       page.HasCursor,
+		CursorCreatedAt: page.Cursor.CreatedAt,
+		CursorID:        page.Cursor.ID,
+		PageLimit:       page.Limit + 1,
+	})
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to get the timeline", err)
+		return
+	}
+
+	if len(chirps) > int(page.Limit) {
+		chirps = chirps[:page.Limit]
+		last := chirps[len(chirps)-1]
+		setNextPageLink(res, req, pageCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+
+	response, err := cfg.chirpResponses(req.Context(), chirps, userIdFromJWT)
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to get the timeline", err)
+		return
+	}
+	respondWithJSON(res, http.StatusOK, response)
+}
@@ -0,0 +1,10 @@
+package main
+
+import "net/http"
+
+// handleJWKS publishes the public keys access tokens are signed with so other
+// services can verify them.
+func (cfg *apiConfig) handleJWKS(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Cache-Control", "public, max-age=300")
+	respondWithJSON(res, http.StatusOK, cfg.jwtKeys.JWKS())
+}
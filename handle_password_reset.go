@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/nacen-dev/chirpy/internal/auth"
+	"github.com/nacen-dev/chirpy/internal/database"
+	"github.com/nacen-dev/chirpy/internal/mail"
+	"github.com/nacen-dev/chirpy/internal/throttle"
+)
+
+const passwordResetTokenLifetime = time.Hour
+
+const passwordResetTimeout = 30 * time.Second
+
+// Every reset request counts against the submitted email and the client's IP
+// address, whether or not a mail is sent, so the limits can't be used to
+// find out which emails are registered. maxPendingPasswordResets bounds how
+// many resets are handled in the background at once.
+var (
+	passwordResetEmailPolicy = throttle.Policy{
+		FreeFailures: 3,
+		BaseDelay:    time.Minute,
+		MaxDelay:     time.Hour,
+		Window:       24 * time.Hour,
+	}
+	passwordResetIPPolicy = throttle.Policy{
+		FreeFailures: 20,
+		BaseDelay:    30 * time.Second,
+		MaxDelay:     time.Hour,
+		Window:       time.Hour,
+	}
+)
+
+const maxPendingPasswordResets = 16
+
+type passwordResetLimits struct {
+	emails *throttle.Throttler
+	ips    *throttle.Throttler
+	// pending holds a slot for every reset being handled in the background.
+	pending chan struct{}
+}
+
+func newPasswordResetLimits(store throttle.Store) passwordResetLimits {
+	return passwordResetLimits{
+		emails:  throttle.New(store, "reset-email", passwordResetEmailPolicy),
+		ips:     throttle.New(store, "reset-ip", passwordResetIPPolicy),
+		pending: make(chan struct{}, maxPendingPasswordResets),
+	}
+}
+
+// reservePasswordReset counts a reset request for email from ipAddress. It
+// returns how much longer requests are refused, or 0 if this one may go
+// ahead.
+func (cfg *apiConfig) reservePasswordReset(ctx context.Context, email string, ipAddress string) (time.Duration, error) {
+	emailRetryAfter, err := cfg.passwordResetLimits.emails.Attempt(ctx, accountThrottleKey(email))
+	if err != nil || emailRetryAfter > 0 {
+		return emailRetryAfter, err
+	}
+	ipRetryAfter, err := cfg.passwordResetLimits.ips.Attempt(ctx, ipAddress)
+	if err != nil || ipRetryAfter > 0 {
+		releaseErr := cfg.passwordResetLimits.emails.Release(ctx, accountThrottleKey(email))
+		if releaseErr != nil {
+			log.Printf("Unable to release password reset request for email: %s", releaseErr)
+		}
+		return ipRetryAfter, err
+	}
+	return 0, nil
+}
+
+// sendPasswordReset mails a reset token to email if it belongs to an
+// account. An unknown email isn't an error.
+func (cfg *apiConfig) sendPasswordReset(ctx context.Context, email string) error {
+	user, err := cfg.db.GetUserByEmail(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return err
+	}
+
+	// Requesting a new token invalidates any earlier one.
+	err = cfg.db.CreatePasswordResetToken(ctx, database.CreatePasswordResetTokenParams{
+		UserID:          user.ID,
+		TokenHash:       auth.HashToken(token),
+		LifetimeSeconds: passwordResetTokenLifetime.Seconds(),
+	})
+	if err != nil {
+		return err
+	}
+
+	resetURL := cfg.publicURL + "/app/reset-password?token=" + url.QueryEscape(token)
+	return cfg.mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Reset your Chirpy password",
+		Body: fmt.Sprintf("Someone asked to reset the password of your Chirpy account.\n\n"+
+			"To choose a new password, open %s\n\n"+
+			"or use this reset token: %s\n\n"+
+			"It expires in %s. If you didn't ask for this, you can ignore this email.\n",
+			resetURL, token, passwordResetTokenLifetime),
+	})
+}
+
+func (cfg *apiConfig) handleRequestPasswordReset(res http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Email string `json:"email"`
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		return
+	}
+
+	_, ipAddress := clientInfo(req)
+	retryAfter, err := cfg.reservePasswordReset(req.Context(), params.Email, ipAddress)
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to check the password reset limits", err)
+		return
+	}
+	if retryAfter > 0 {
+		res.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(retryAfter)))
+		respondWithError(res, http.StatusTooManyRequests, "Too many password reset requests, try again later", nil)
+		return
+	}
+
+	select {
+	case cfg.passwordResetLimits.pending <- struct{}{}:
+	default:
+		res.Header().Set("Retry-After", "1")
+		respondWithError(res, http.StatusServiceUnavailable, "Too many password resets in progress, try again later", nil)
+		return
+	}
+
+	// The response is the same whether or not the email belongs to an
+	// account. The lookup, the token and the mail are all handled in the
+	// background, so the response time doesn't give it away either. The
+	// work outlives the request but not passwordResetTimeout.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(req.Context()), passwordResetTimeout)
+	go func() {
+		defer func() { <-cfg.passwordResetLimits.pending }()
+		defer cancel()
+
+		err := cfg.sendPasswordReset(ctx, params.Email)
+		if err != nil {
+			log.Printf("Unable to send password reset: %s", err)
+		}
+	}()
+	res.WriteHeader(http.StatusAccepted)
+}
+
+func (cfg *apiConfig) handleConfirmPasswordReset(res http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		return
+	}
+
+	if !cfg.checkPasswordPolicy(res, params.Password) {
+		return
+	}
+
+	hashedPassword, err := cfg.passwordHasher.Hash(params.Password)
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "unable to hash the password", err)
+		return
+	}
+
+	// The token is used up in the same statement that sets the password, so
+	// it works at most once even if it is submitted twice at the same time.
+	userId, err := cfg.db.ResetPasswordWithToken(req.Context(), database.ResetPasswordWithTokenParams{
+		TokenHash:      auth.HashToken(params.Token),
+		HashedPassword: hashedPassword,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(res, http.StatusBadRequest, "Invalid or expired reset token", err)
+			return
+		}
+		respondWithError(res, http.StatusInternalServerError, "Unable to reset the password", err)
+		return
+	}
+
+	err = cfg.signOutEverywhere(req.Context(), userId)
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "unable to sign out the user's sessions", err)
+		return
+	}
+
+	res.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/nacen-dev/chirpy/internal/auth"
+	"github.com/nacen-dev/chirpy/internal/database"
+)
+
+type PersonalAccessToken struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
+
+func personalAccessTokenFromDB(token database.PersonalAccessToken) PersonalAccessToken {
+	response := PersonalAccessToken{
+		ID:        token.ID,
+		Name:      token.Name,
+		Scopes:    token.Scopes,
+		CreatedAt: token.CreatedAt,
+	}
+	if token.LastUsedAt.Valid {
+		response.LastUsedAt = &token.LastUsedAt.Time
+	}
+	if token.ExpiresAt.Valid {
+		response.ExpiresAt = &token.ExpiresAt.Time
+	}
+	return response
+}
+
+const (
+	maxTokenNameLength     = 100
+	maxTokenLifetimeInDays = 365
+)
+
+func (cfg *apiConfig) handleCreatePersonalAccessToken(res http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+		// ExpiresInDays is optional; tokens without it never expire.
+		ExpiresInDays int `json:"expires_in_days"`
+	}
+	type response struct {
+		PersonalAccessToken
+		// Token is only ever shown here; just its hash is stored.
+		Token string `json:"token"`
+	}
+
+	userId, err := cfg.authenticatedUserID(req, scopeAccount)
+	if err != nil {
+		respondWithError(res, authErrorStatus(err), "Invalid or missing token", err)
+		return
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		return
+	}
+
+	name := strings.TrimSpace(params.Name)
+	if name == "" || utf8.RuneCountInString(name) > maxTokenNameLength {
+		respondWithError(res, http.StatusBadRequest, "name must be 1 to 100 characters", nil)
+		return
+	}
+
+	if len(params.Scopes) == 0 {
+		respondWithError(res, http.StatusBadRequest, "At least one scope is required", nil)
+		return
+	}
+	scopes := []string{}
+	for _, scope := range params.Scopes {
+		if !slices.Contains(grantableScopes, scope) {
+			respondWithError(res, http.StatusBadRequest, "Unknown scope "+scope+", must be one of "+strings.Join(grantableScopes, ", "), nil)
+			return
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	expiresAt := sql.NullTime{}
+	if params.ExpiresInDays != 0 {
+		if params.ExpiresInDays < 0 || params.ExpiresInDays > maxTokenLifetimeInDays {
+			respondWithError(res, http.StatusBadRequest, "expires_in_days must be between 1 and 365", nil)
+			return
+		}
+		expiresAt = sql.NullTime{Time: time.Now().AddDate(0, 0, params.ExpiresInDays), Valid: true}
+	}
+
+	token, err := auth.MakePersonalAccessToken()
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to create the token", err)
+		return
+	}
+
+	personalAccessToken, err := cfg.db.CreatePersonalAccessToken(req.Context(), database.CreatePersonalAccessTokenParams{
+		UserID:    userId,
+		Name:      name,
+		TokenHash: auth.HashToken(token),
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to create the token", err)
+		return
+	}
+
+	respondWithJSON(res, http.StatusCreated, response{
+		PersonalAccessToken: personalAccessTokenFromDB(personalAccessToken),
+		Token:               token,
+	})
+}
+
+func (cfg *apiConfig) handleGetPersonalAccessTokens(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticatedUserID(req, scopeAccount)
+	if err != nil {
+		respondWithError(res, authErrorStatus(err), "Invalid or missing token", err)
+		return
+	}
+
+	tokens, err := cfg.db.GetPersonalAccessTokens(req.Context(), userId)
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to get tokens", err)
+		return
+	}
+
+	response := []PersonalAccessToken{}
+	for _, token := range tokens {
+		response = append(response, personalAccessTokenFromDB(token))
+	}
+	respondWithJSON(res, http.StatusOK, response)
+}
+
+func (cfg *apiConfig) handleRevokePersonalAccessToken(res http.ResponseWriter, req *http.Request) {
+	tokenId, err := uuid.Parse(req.PathValue("tokenId"))
+	if err != nil {
+		respondWithError(res, http.StatusBadRequest, "Invalid token id", err)
+		return
+	}
+
+	userId, err := cfg.authenticatedUserID(req, scopeAccount)
+	if err != nil {
+		respondWithError(res, authErrorStatus(err), "Invalid or missing token", err)
+		return
+	}
+
+	revoked, err := cfg.db.RevokePersonalAccessToken(req.Context(), database.RevokePersonalAccessTokenParams{
+		ID:     tokenId,
+		UserID: userId,
+	})
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to revoke the token", err)
+		return
+	}
+	if revoked == 0 {
+		respondWithError(res, http.StatusNotFound, "Couldn't find token", nil)
+		return
+	}
+	res.WriteHeader(http.StatusNoContent)
+}
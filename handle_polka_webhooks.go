@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nacen-dev/chirpy/internal/auth"
+	"github.com/nacen-dev/chirpy/internal/database"
+	"github.com/nacen-dev/chirpy/internal/subscription"
+)
+
+const (
+	maxPolkaWebhookBytes    = 1 << 20
+	polkaSignatureTolerance = 5 * time.Minute
+	webhookFinishTimeout    = 10 * time.Second
+)
+
+const webhookProviderPolka = "polka"
+
+// Statuses of a webhook event. An event is "processing" from when it is
+// received or retried until its outcome is stored.
+const (
+	webhookStatusProcessing = "processing"
+	webhookStatusProcessed  = "processed"
+	webhookStatusIgnored    = "ignored"
+	webhookStatusFailed     = "failed"
+)
+
+type polkaEvent struct {
+	// ID identifies the event across Polka's retries. Older payloads
+	// don't have one.
+	ID    string `json:"id"`
+	Event string `json:"event"`
+	Data  struct {
+		UserId uuid.UUID `json:"user_id"`
+		// Plan and CurrentPeriodEnd are sent with upgrades and renewals.
+		// Without them the plan stays the same and the period is extended
+		// by a month.
+		Plan             *string    `json:"plan"`
+		CurrentPeriodEnd *time.Time `json:"current_period_end"`
+	} `json:"data"`
+}
+
+// webhookOutcome is the result of processing an event and the response every
+// delivery of it gets.
+type webhookOutcome struct {
+	Status         string
+	ResponseStatus int
+	Err            error
+}
+
+func (cfg *apiConfig) handlePolkaEvent(ctx context.Context, webhookEvent database.WebhookEvent) webhookOutcome {
+	event := polkaEvent{}
+	err := json.Unmarshal(webhookEvent.Payload, &event)
+	if err != nil {
+		return webhookOutcome{Status: webhookStatusFailed, ResponseStatus: http.StatusBadRequest, Err: err}
+	}
+
+	// The Polka events that change a subscription. Anything else is ignored.
+	switch event.Event {
+	case subscription.EventUpgraded, subscription.EventRenewed, subscription.EventPaymentFailed, subscription.EventDowngraded:
+	default:
+		return webhookOutcome{Status: webhookStatusIgnored, ResponseStatus: http.StatusNoContent}
+	}
+
+	_, err = cfg.db.GetUserById(ctx, event.Data.UserId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return webhookOutcome{Status: webhookStatusFailed, ResponseStatus: http.StatusNotFound, Err: errors.New("Couldn't find user")}
+		}
+		return webhookOutcome{Status: webhookStatusFailed, ResponseStatus: http.StatusInternalServerError, Err: err}
+	}
+
+	webhookEventId := uuid.NullUUID{UUID: webhookEvent.ID, Valid: true}
+	transition := subscription.For(event.Event)
+	chirpyRed := subscription.ChirpyRed(transition.To)
+	switch event.Event {
+	case subscription.EventUpgraded, subscription.EventRenewed:
+		params := database.ActivateSubscriptionParams{
+			UserID:         event.Data.UserId,
+			Status:         transition.To,
+			FromStatuses:   transition.From,
+			Event:          event.Event,
+			WebhookEventID: webhookEventId,
+			ChirpyRed:      chirpyRed,
+		}
+		if event.Data.Plan != nil {
+			params.Plan = sql.NullString{String: *event.Data.Plan, Valid: true}
+		}
+		if event.Data.CurrentPeriodEnd != nil {
+			params.CurrentPeriodEnd = sql.NullTime{Time: event.Data.CurrentPeriodEnd.UTC(), Valid: true}
+		}
+		_, err = cfg.db.ActivateSubscription(ctx, params)
+	case subscription.EventPaymentFailed:
+		_, err = cfg.db.MarkSubscriptionPastDue(ctx, database.MarkSubscriptionPastDueParams{
+			Status:         transition.To,
+			UserID:         event.Data.UserId,
+			FromStatuses:   transition.From,
+			Event:          event.Event,
+			WebhookEventID: webhookEventId,
+			ChirpyRed:      chirpyRed,
+		})
+	case subscription.EventDowngraded:
+		err = cfg.db.CancelSubscription(ctx, database.CancelSubscriptionParams{
+			Status:         transition.To,
+			UserID:         event.Data.UserId,
+			FromStatuses:   transition.From,
+			Event:          event.Event,
+			WebhookEventID: webhookEventId,
+			ChirpyRed:      chirpyRed,
+		})
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		// The subscription is in a status the event doesn't apply to.
+		return webhookOutcome{Status: webhookStatusIgnored, ResponseStatus: http.StatusNoContent}
+	}
+	if err != nil {
+		return webhookOutcome{Status: webhookStatusFailed, ResponseStatus: http.StatusInternalServerError, Err: err}
+	}
+	return webhookOutcome{Status: webhookStatusProcessed, ResponseStatus: http.StatusNoContent}
+}
+
+// processWebhookEvent handles an event that is marked as processing and
+// stores the outcome. The outcome is stored even if the request that
+// delivered the event is cancelled in the meantime, so the event doesn't stay
+// processing until its claim runs out.
+func (cfg *apiConfig) processWebhookEvent(ctx context.Context, event database.WebhookEvent) (database.WebhookEvent, error) {
+	outcome := cfg.handlePolkaEvent(ctx, event)
+
+	errorMessage := sql.NullString{}
+	if outcome.Err != nil {
+		errorMessage = sql.NullString{String: outcome.Err.Error(), Valid: true}
+	}
+	finishCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), webhookFinishTimeout)
+	defer cancel()
+	return cfg.db.FinishWebhookEvent(finishCtx, database.FinishWebhookEventParams{
+		ID:             event.ID,
+		Status:         outcome.Status,
+		ResponseStatus: sql.NullInt32{Int32: int32(outcome.ResponseStatus), Valid: true},
+		Error:          errorMessage,
+	})
+}
+
+// respondWithWebhookOutcome answers a delivery with the stored outcome of
+// its event. Details of server errors stay in the event log.
+func respondWithWebhookOutcome(res http.ResponseWriter, event database.WebhookEvent) {
+	status := int(event.ResponseStatus.Int32)
+	switch {
+	case status >= 500:
+		respondWithError(res, status, "Unable to process the event", nil)
+	case status >= 400:
+		respondWithError(res, status, event.Error.String, nil)
+	default:
+		res.WriteHeader(status)
+	}
+}
+
+// handleUpgradeToChirpyRed receives Polka's webhooks. Polka retries a
+// delivery until it gets a 2xx, so every event is stored and deduplicated on
+// its ID: a retry of an event that was already handled gets the same
+// response again without handling it twice. Only events that failed on our
+// side with a 5xx are handled again when retried. Events without an ID are
+// handled every time they are delivered.
+func (cfg *apiConfig) handleUpgradeToChirpyRed(res http.ResponseWriter, req *http.Request) {
+	// The signature covers the raw body, so it is read in full before
+	// decoding.
+	body, err := io.ReadAll(http.MaxBytesReader(res, req.Body, maxPolkaWebhookBytes))
+	if err != nil {
+		respondWithError(res, http.StatusBadRequest, "Couldn't read the request body", err)
+		return
+	}
+
+	err = auth.VerifyPolkaSignature(req.Header, body, cfg.polkaWebhookSecrets, time.Now(), polkaSignatureTolerance)
+	if err != nil {
+		respondWithError(res, http.StatusUnauthorized, "invalid webhook signature", err)
+		return
+	}
+
+	params := polkaEvent{}
+	err = json.Unmarshal(body, &params)
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		return
+	}
+
+	// Events without an ID can't be told apart from a later event with the
+	// same content, like a second upgrade or another month's renewal, so
+	// they are recorded under an ID of their own and never deduplicated.
+	eventId := params.ID
+	if eventId == "" {
+		eventId = "unidentified:" + uuid.NewString()
+	}
+
+	event, err := cfg.db.RecordWebhookEvent(req.Context(), database.RecordWebhookEventParams{
+		Provider:  webhookProviderPolka,
+		EventID:   eventId,
+		EventType: params.Event,
+		Payload:   body,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		existing, err := cfg.db.GetWebhookEventByEventId(req.Context(), database.GetWebhookEventByEventIdParams{
+			Provider: webhookProviderPolka,
+			EventID:  eventId,
+		})
+		if err != nil {
+			respondWithError(res, http.StatusInternalServerError, "Unable to process the event", err)
+			return
+		}
+		failedOnOurSide := existing.Status == webhookStatusFailed && existing.ResponseStatus.Int32 >= 500
+		if existing.Status != webhookStatusProcessing && !failedOnOurSide {
+			respondWithWebhookOutcome(res, existing)
+			return
+		}
+		// An event that is still processing is only claimed again once it
+		// has been processing for so long that it must have been abandoned.
+		event, err = cfg.db.RetryWebhookEvent(req.Context(), existing.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(res, http.StatusConflict, "The event is already being processed", nil)
+			return
+		}
+		if err != nil {
+			respondWithError(res, http.StatusInternalServerError, "Unable to process the event", err)
+			return
+		}
+	} else if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to process the event", err)
+		return
+	}
+
+	event, err = cfg.processWebhookEvent(req.Context(), event)
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to process the event", err)
+		return
+	}
+	if event.Status == webhookStatusFailed {
+		log.Printf("Polka event %s failed: %s", event.EventID, event.Error.String)
+	}
+	respondWithWebhookOutcome(res, event)
+}
@@ -1,12 +1,47 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"log"
 	"net/http"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/nacen-dev/chirpy/internal/auth"
+	"github.com/nacen-dev/chirpy/internal/database"
 )
 
+const refreshTokenLifetime = 60 * 24 * time.Hour
+
+// issueRefreshToken stores a new refresh token for the user. Every login
+// starts a new session and every refresh continues the session of the token
+// it replaces; a session's ID doubles as its token family ID. Only the
+// token's hash is stored; the token itself is returned for the client.
+func (cfg *apiConfig) issueRefreshToken(ctx context.Context, userId uuid.UUID, familyId uuid.UUID) (string, error) {
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = cfg.db.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		TokenHash: auth.HashToken(refreshToken),
+		UserID:    userId,
+		ExpiresAt: time.Now().Add(refreshTokenLifetime),
+		RevokedAt: sql.NullTime{},
+		FamilyID:  familyId,
+	})
+	if err != nil {
+		return "", err
+	}
+	return refreshToken, nil
+}
+
+// handleRefresh rotates the refresh token: the presented token is revoked
+// and a new one from the same family is returned along with the access
+// token. A token that has already been rotated or revoked being presented
+// again means it has leaked, so the whole family is revoked and the user has
+// to log in again.
 func (cfg *apiConfig) handleRefresh(res http.ResponseWriter, req *http.Request) {
 	token, err := auth.GetBearerToken(req.Header)
 
@@ -15,38 +50,84 @@ func (cfg *apiConfig) handleRefresh(res http.ResponseWriter, req *http.Request)
 		return
 	}
 
-	refreshTokenData, err := cfg.db.GetUserFromRefreshToken(req.Context(), token)
+	tokenHash := auth.HashToken(token)
+	refreshTokenData, err := cfg.db.GetUserFromRefreshToken(req.Context(), tokenHash)
 	if err != nil {
 		respondWithError(res, http.StatusUnauthorized, "Unable to get user from the refresh token", err)
 		return
 	}
 
-	if refreshTokenData.RevokedAt.Valid || time.Now().After(refreshTokenData.ExpiresAt) {
-		respondWithError(res, http.StatusUnauthorized, "Token is expired or revoked", err)
+	if refreshTokenData.RevokedAt.Valid {
+		cfg.revokeRefreshTokenFamily(req.Context(), refreshTokenData.FamilyID)
+		respondWithError(res, http.StatusUnauthorized, "Token is expired or revoked", nil)
+		return
+	}
+
+	if time.Now().After(refreshTokenData.ExpiresAt) {
+		respondWithError(res, http.StatusUnauthorized, "Token is expired or revoked", nil)
+		return
+	}
+
+	// Only one of two concurrent refreshes with the same token gets to
+	// revoke it; the other one is treated as reuse.
+	revoked, err := cfg.db.RevokeActiveRefreshToken(req.Context(), tokenHash)
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to rotate the refresh token", err)
+		return
+	}
+	if revoked == 0 {
+		cfg.revokeRefreshTokenFamily(req.Context(), refreshTokenData.FamilyID)
+		respondWithError(res, http.StatusUnauthorized, "Token is expired or revoked", nil)
+		return
+	}
+
+	refreshToken, err := cfg.issueRefreshToken(req.Context(), refreshTokenData.UserID, refreshTokenData.FamilyID)
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to rotate the refresh token", err)
 		return
 	}
 
-	accessToken, err := auth.MakeJWT(refreshTokenData.UserID, cfg.jwtSecret, time.Duration(auth.DefaultExpirationInHours)*time.Hour)
+	userAgent, ipAddress := clientInfo(req)
+	err = cfg.db.TouchSession(req.Context(), database.TouchSessionParams{
+		ID:        refreshTokenData.FamilyID,
+		UserAgent: userAgent,
+		IpAddress: ipAddress,
+	})
+	if err != nil {
+		log.Printf("Unable to update session %s: %s", refreshTokenData.FamilyID, err)
+	}
+
+	accessToken, err := cfg.jwtKeys.MakeJWT(refreshTokenData.UserID, refreshTokenData.TokenVersion, time.Duration(auth.DefaultExpirationInHours)*time.Hour)
 	if err != nil {
 		respondWithError(res, http.StatusUnauthorized, "Unable to create token", err)
 		return
 	}
 	type response struct {
-		Token string `json:"token"`
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
 	}
 	respondWithJSON(res, http.StatusOK, response{
-		Token: accessToken,
+		Token:        accessToken,
+		RefreshToken: refreshToken,
 	})
 
 }
 
+func (cfg *apiConfig) revokeRefreshTokenFamily(ctx context.Context, familyId uuid.UUID) {
+	log.Printf("Refresh token reuse detected, revoking token family %s", familyId)
+	err := cfg.db.RevokeRefreshTokenFamily(ctx, familyId)
+	if err != nil {
+		log.Printf("Unable to revoke token family %s: %s", familyId, err)
+	}
+}
+
 func (cfg *apiConfig) handleRevoke(res http.ResponseWriter, req *http.Request) {
 	token, err := auth.GetBearerToken(req.Header)
 	if err != nil {
 		respondWithError(res, http.StatusUnauthorized, "No token found", err)
 		return
 	}
-	err = cfg.db.RevokeRefreshToken(req.Context(), token)
+	err = cfg.db.RevokeRefreshToken(req.Context(), auth.HashToken(token))
 	if err != nil {
 		respondWithError(res, http.StatusInternalServerError, "unable to revoke the token", err)
 	}
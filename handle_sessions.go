@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/nacen-dev/chirpy/internal/database"
+)
+
+// Session is a login on one device: the chain of refresh tokens started by a
+// login and continued by every refresh.
+type Session struct {
+	ID         uuid.UUID `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+}
+
+const maxUserAgentLength = 512
+
+// clientInfo returns the user agent and IP address recorded for a session.
+// The IP address is the direct peer's; X-Forwarded-For is ignored because any
+// client can set it.
+func clientInfo(req *http.Request) (userAgent string, ipAddress string) {
+	// Postgres only stores valid UTF-8, so invalid bytes are replaced and
+	// the user agent is cut at a rune boundary.
+	userAgent = strings.ToValidUTF8(req.UserAgent(), "\uFFFD")
+	if len(userAgent) > maxUserAgentLength {
+		end := maxUserAgentLength
+		for end > 0 && !utf8.RuneStart(userAgent[end]) {
+			end--
+		}
+		userAgent = userAgent[:end]
+	}
+
+	ipAddress, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		ipAddress = req.RemoteAddr
+	}
+	return userAgent, ipAddress
+}
+
+// signOutEverywhere revokes all of the user's sessions and personal access
+// tokens and bumps their token version, so none of their refresh tokens,
+// personal access tokens or access tokens work anymore. Otherwise whoever
+// got hold of an access token could mint a personal access token that
+// outlives a password change.
+func (cfg *apiConfig) signOutEverywhere(ctx context.Context, userId uuid.UUID) error {
+	err := cfg.db.RevokeAllSessions(ctx, userId)
+	if err != nil {
+		return err
+	}
+	err = cfg.db.RevokeAllPersonalAccessTokens(ctx, userId)
+	if err != nil {
+		return err
+	}
+	return cfg.db.BumpTokenVersion(ctx, userId)
+}
+
+func (cfg *apiConfig) handleGetSessions(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticatedUserID(req, scopeAccount)
+	if err != nil {
+		respondWithError(res, authErrorStatus(err), "Invalid or missing token", err)
+		return
+	}
+
+	sessions, err := cfg.db.GetActiveSessions(req.Context(), userId)
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to get sessions", err)
+		return
+	}
+
+	response := []Session{}
+	for _, session := range sessions {
+		response = append(response, Session{
+			ID:         session.ID,
+			CreatedAt:  session.CreatedAt,
+			LastUsedAt: session.LastUsedAt,
+			UserAgent:  session.UserAgent,
+			IPAddress:  session.IpAddress,
+		})
+	}
+	respondWithJSON(res, http.StatusOK, response)
+}
+
+func (cfg *apiConfig) handleRevokeSession(res http.ResponseWriter, req *http.Request) {
+	sessionId, err := uuid.Parse(req.PathValue("sessionId"))
+	if err != nil {
+		respondWithError(res, http.StatusBadRequest, "Invalid session id", err)
+		return
+	}
+
+	userId, err := cfg.authenticatedUserID(req, scopeAccount)
+	if err != nil {
+		respondWithError(res, authErrorStatus(err), "Invalid or missing token", err)
+		return
+	}
+
+	revoked, err := cfg.db.RevokeSession(req.Context(), database.RevokeSessionParams{
+		ID:     sessionId,
+		UserID: userId,
+	})
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to revoke the session", err)
+		return
+	}
+	// Other users' sessions are reported as missing rather than forbidden so
+	// their IDs can't be probed.
+	if revoked == 0 {
+		respondWithError(res, http.StatusNotFound, "Couldn't find session", nil)
+		return
+	}
+	res.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handleRevokeAllSessions(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticatedUserID(req, scopeAccount)
+	if err != nil {
+		respondWithError(res, authErrorStatus(err), "Invalid or missing token", err)
+		return
+	}
+
+	err = cfg.signOutEverywhere(req.Context(), userId)
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to revoke the sessions", err)
+		return
+	}
+	res.WriteHeader(http.StatusNoContent)
+}
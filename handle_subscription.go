@@ -0,0 +1,78 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Subscription struct {
+	ID               uuid.UUID           `json:"id"`
+	Plan             string              `json:"plan"`
+	Status           string              `json:"status"`
+	CurrentPeriodEnd time.Time           `json:"current_period_end"`
+	CanceledAt       *time.Time          `json:"canceled_at"`
+	CreatedAt        time.Time           `json:"created_at"`
+	UpdatedAt        time.Time           `json:"updated_at"`
+	History          []SubscriptionEvent `json:"history"`
+}
+
+// SubscriptionEvent is a change to a subscription and the status it left it
+// in.
+type SubscriptionEvent struct {
+	Event            string    `json:"event"`
+	Status           string    `json:"status"`
+	CurrentPeriodEnd time.Time `json:"current_period_end"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// handleGetSubscription returns the user's Chirpy Red subscription with its
+// history, newest first.
+func (cfg *apiConfig) handleGetSubscription(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticatedUserID(req, scopeAccount)
+	if err != nil {
+		respondWithError(res, authErrorStatus(err), "Invalid or missing token", err)
+		return
+	}
+
+	subscription, err := cfg.db.GetSubscriptionByUserId(req.Context(), userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(res, http.StatusNotFound, "No subscription found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to get the subscription", err)
+		return
+	}
+
+	events, err := cfg.db.GetSubscriptionEvents(req.Context(), subscription.ID)
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to get the subscription", err)
+		return
+	}
+
+	response := Subscription{
+		ID:               subscription.ID,
+		Plan:             subscription.Plan,
+		Status:           subscription.Status,
+		CurrentPeriodEnd: subscription.CurrentPeriodEnd,
+		CreatedAt:        subscription.CreatedAt,
+		UpdatedAt:        subscription.UpdatedAt,
+		History:          []SubscriptionEvent{},
+	}
+	if subscription.CanceledAt.Valid {
+		response.CanceledAt = &subscription.CanceledAt.Time
+	}
+	for _, event := range events {
+		response.History = append(response.History, SubscriptionEvent{
+			Event:            event.Event,
+			Status:           event.Status,
+			CurrentPeriodEnd: event.CurrentPeriodEnd,
+			CreatedAt:        event.CreatedAt,
+		})
+	}
+	respondWithJSON(res, http.StatusOK, response)
+}
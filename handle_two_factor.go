@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nacen-dev/chirpy/internal/auth"
+	"github.com/nacen-dev/chirpy/internal/database"
+)
+
+const (
+	totpIssuer           = "Chirpy"
+	recoveryCodeCount    = 10
+	mfaChallengeLifetime = 5 * time.Minute
+)
+
+// verifySecondFactor checks a TOTP code or, if one is given, a recovery code
+// for a user with 2FA enabled. Either can only be used once: the TOTP step
+// and the recovery code are marked as used in the same statement that checks
+// they weren't already, so concurrent requests can't both succeed.
+func (cfg *apiConfig) verifySecondFactor(ctx context.Context, user database.User, code string, recoveryCode string) (bool, error) {
+	if recoveryCode != "" {
+		used, err := cfg.db.UseRecoveryCode(ctx, database.UseRecoveryCodeParams{
+			UserID:   user.ID,
+			CodeHash: auth.HashToken(auth.NormalizeRecoveryCode(recoveryCode)),
+		})
+		return used == 1, err
+	}
+
+	step, ok := auth.VerifyTOTP(user.TotpSecret.String, code, time.Now(), user.TotpLastUsedStep)
+	if !ok {
+		return false, nil
+	}
+	used, err := cfg.db.UseTOTPStep(ctx, database.UseTOTPStepParams{
+		Step: step,
+		ID:   user.ID,
+	})
+	return used == 1, err
+}
+
+func (cfg *apiConfig) handleSetupTwoFactor(res http.ResponseWriter, req *http.Request) {
+	type response struct {
+		Secret     string `json:"secret"`
+		OTPAuthURI string `json:"otpauth_uri"`
+	}
+
+	userId, err := cfg.authenticatedUserID(req, scopeAccount)
+	if err != nil {
+		respondWithError(res, authErrorStatus(err), "Invalid or missing token", err)
+		return
+	}
+
+	user, err := cfg.db.GetUserById(req.Context(), userId)
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "unable to retrieve the user", err)
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to set up 2FA", err)
+		return
+	}
+
+	// Setting up again before confirming replaces the pending secret, but an
+	// enabled secret can only be replaced by disabling 2FA first.
+	updated, err := cfg.db.SetPendingTOTPSecret(req.Context(), database.SetPendingTOTPSecretParams{
+		TotpSecret: secret,
+		ID:         user.ID,
+	})
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to set up 2FA", err)
+		return
+	}
+	if updated == 0 {
+		respondWithError(res, http.StatusConflict, "2FA is already enabled", nil)
+		return
+	}
+
+	accountName := user.Email
+	if user.Username.Valid {
+		accountName = user.Username.String
+	}
+	respondWithJSON(res, http.StatusOK, response{
+		Secret:     secret,
+		OTPAuthURI: auth.TOTPURI(secret, totpIssuer, accountName),
+	})
+}
+
+func (cfg *apiConfig) handleConfirmTwoFactor(res http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Code string `json:"code"`
+	}
+	type response struct {
+		// RecoveryCodes are only ever shown here; just their hashes are
+		// stored.
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+
+	userId, err := cfg.authenticatedUserID(req, scopeAccount)
+	if err != nil {
+		respondWithError(res, authErrorStatus(err), "Invalid or missing token", err)
+		return
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		return
+	}
+
+	user, err := cfg.db.GetUserById(req.Context(), userId)
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "unable to retrieve the user", err)
+		return
+	}
+	if user.TotpEnabledAt.Valid {
+		respondWithError(res, http.StatusConflict, "2FA is already enabled", nil)
+		return
+	}
+	if !user.TotpSecret.Valid {
+		respondWithError(res, http.StatusBadRequest, "2FA has to be set up first", nil)
+		return
+	}
+
+	step, ok := auth.VerifyTOTP(user.TotpSecret.String, params.Code, time.Now(), 0)
+	if !ok {
+		respondWithError(res, http.StatusBadRequest, "Invalid code", nil)
+		return
+	}
+
+	recoveryCodes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to enable 2FA", err)
+		return
+	}
+	codeHashes := make([]string, 0, len(recoveryCodes))
+	for _, recoveryCode := range recoveryCodes {
+		codeHashes = append(codeHashes, auth.HashToken(recoveryCode))
+	}
+	err = cfg.db.ReplaceRecoveryCodes(req.Context(), database.ReplaceRecoveryCodesParams{
+		UserID:     user.ID,
+		CodeHashes: codeHashes,
+	})
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to enable 2FA", err)
+		return
+	}
+
+	enabled, err := cfg.db.EnableTOTP(req.Context(), database.EnableTOTPParams{
+		ID:               user.ID,
+		TotpLastUsedStep: step,
+	})
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to enable 2FA", err)
+		return
+	}
+	if enabled == 0 {
+		respondWithError(res, http.StatusConflict, "2FA is already enabled", nil)
+		return
+	}
+
+	respondWithJSON(res, http.StatusOK, response{
+		RecoveryCodes: recoveryCodes,
+	})
+}
+
+func (cfg *apiConfig) handleDisableTwoFactor(res http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+
+	userId, err := cfg.authenticatedUserID(req, scopeAccount)
+	if err != nil {
+		respondWithError(res, authErrorStatus(err), "Invalid or missing token", err)
+		return
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		return
+	}
+
+	user, err := cfg.db.GetUserById(req.Context(), userId)
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "unable to retrieve the user", err)
+		return
+	}
+	if !user.TotpEnabledAt.Valid {
+		respondWithError(res, http.StatusBadRequest, "2FA is not enabled", nil)
+		return
+	}
+
+	// A stolen access token alone isn't enough to turn 2FA off.
+	ok, err := cfg.verifySecondFactor(req.Context(), user, params.Code, params.RecoveryCode)
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to disable 2FA", err)
+		return
+	}
+	if !ok {
+		respondWithError(res, http.StatusUnauthorized, "Invalid code", nil)
+		return
+	}
+
+	err = cfg.db.DisableTOTP(req.Context(), user.ID)
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to disable 2FA", err)
+		return
+	}
+	res.WriteHeader(http.StatusNoContent)
+}
+
+// handleLoginTwoFactor finishes a login for a user with 2FA enabled,
+// exchanging the challenge token from /api/login and a TOTP or recovery code
+// for access and refresh tokens.
+func (cfg *apiConfig) handleLoginTwoFactor(res http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		MFAToken     string `json:"mfa_token"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		return
+	}
+
+	userId, err := cfg.jwtKeys.ValidateMFAChallengeJWT(params.MFAToken)
+	if err != nil {
+		respondWithError(res, http.StatusUnauthorized, "Invalid or expired challenge", err)
+		return
+	}
+
+	user, err := cfg.db.GetUserById(req.Context(), userId)
+	if err != nil {
+		respondWithError(res, http.StatusUnauthorized, "Invalid or expired challenge", err)
+		return
+	}
+	if !user.TotpEnabledAt.Valid {
+		respondWithError(res, http.StatusUnauthorized, "Invalid or expired challenge", nil)
+		return
+	}
+	if user.SuspendedAt.Valid {
+		respondWithError(res, http.StatusForbidden, "This account is suspended", nil)
+		return
+	}
+
+	// Codes are short enough to guess, so wrong ones count towards the same
+	// lockout as wrong passwords.
+	_, ipAddress := clientInfo(req)
+	retryAfter, err := cfg.reserveLoginAttempt(req.Context(), user.Email, ipAddress)
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to verify the code", err)
+		return
+	}
+	if retryAfter > 0 {
+		respondWithLoginLocked(res, retryAfter)
+		return
+	}
+
+	ok, err := cfg.verifySecondFactor(req.Context(), user, params.Code, params.RecoveryCode)
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to verify the code", err)
+		return
+	}
+	if !ok {
+		cfg.recordLoginFailure(req.Context(), user.Email, ipAddress, uuid.NullUUID{UUID: user.ID, Valid: true})
+		respondWithError(res, http.StatusUnauthorized, "Invalid code", nil)
+		return
+	}
+	cfg.releaseLoginAttempt(req.Context(), user.Email, ipAddress)
+
+	cfg.completeLogin(res, req, user)
+}
@@ -1,13 +1,17 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"log"
 	"net/http"
+	"regexp"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/nacen-dev/chirpy/internal/auth"
 	"github.com/nacen-dev/chirpy/internal/database"
 )
@@ -18,6 +22,59 @@ type User struct {
 	UpdatedAt   time.Time `json:"updated_at"`
 	Email       string    `json:"email"`
 	IsChirpyRed bool      `json:"is_chirpy_red"`
+	// Username is null for accounts created before usernames existed.
+	Username    *string `json:"username"`
+	DisplayName *string `json:"display_name"`
+	Bio         *string `json:"bio"`
+	AvatarURL   *string `json:"avatar_url"`
+	// EmailVerified is whether the user proved they own Email.
+	EmailVerified bool `json:"email_verified"`
+	// PendingEmail is the address the user asked to change to. It replaces
+	// Email once it is verified.
+	PendingEmail *string `json:"pending_email"`
+}
+
+func userFromDB(user database.User) User {
+	return User{
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		IsChirpyRed:   user.IsChirpyRed,
+		Username:      nullStringPtr(user.Username),
+		DisplayName:   nullStringPtr(user.DisplayName),
+		Bio:           nullStringPtr(user.Bio),
+		AvatarURL:     nullStringPtr(user.AvatarUrl),
+		EmailVerified: user.EmailVerifiedAt.Valid,
+		PendingEmail:  nullStringPtr(user.PendingEmail),
+	}
+}
+
+const (
+	minUsernameLength = 3
+	maxUsernameLength = 30
+)
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+func isUsernameValid(username string) bool {
+	return len(username) >= minUsernameLength &&
+		len(username) <= maxUsernameLength &&
+		usernamePattern.MatchString(username)
+}
+
+func nullStringPtr(s sql.NullString) *string {
+	if !s.Valid {
+		return nil
+	}
+	return &s.String
+}
+
+// isUniqueViolation reports whether err comes from Postgres rejecting a write
+// because of the named unique constraint or index.
+func isUniqueViolation(err error, constraint string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == constraint
 }
 
 func (cfg *apiConfig) handleResetUsers(res http.ResponseWriter, req *http.Request) {
@@ -38,6 +95,7 @@ func (cfg *apiConfig) handleCreateUsers(res http.ResponseWriter, req *http.Reque
 	type userRegistrationRequest struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
+		profileParameters
 	}
 	type userRegistrationResponse struct {
 		User
@@ -51,7 +109,19 @@ func (cfg *apiConfig) handleCreateUsers(res http.ResponseWriter, req *http.Reque
 		return
 	}
 
-	hashedPassword, err := auth.HashPassword(params.Password)
+	// Usernames are optional so existing clients keep working, but an
+	// account without one can't be @mentioned.
+	profile, err := params.profileParameters.apply(profileColumns{})
+	if err != nil {
+		respondWithError(res, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	if !cfg.checkPasswordPolicy(res, params.Password) {
+		return
+	}
+
+	hashedPassword, err := cfg.passwordHasher.Hash(params.Password)
 	if err != nil {
 		respondWithError(res, http.StatusInternalServerError, "unable to hash the password", nil)
 		return
@@ -60,21 +130,30 @@ func (cfg *apiConfig) handleCreateUsers(res http.ResponseWriter, req *http.Reque
 	user, err := cfg.db.CreateUser(req.Context(), database.CreateUserParams{
 		Email:          params.Email,
 		HashedPassword: hashedPassword,
+		Username:       profile.Username,
+		DisplayName:    profile.DisplayName,
+		Bio:            profile.Bio,
+		AvatarUrl:      profile.AvatarUrl,
 	})
 
 	if err != nil {
+		if isUniqueViolation(err, "users_username_key") {
+			respondWithError(res, http.StatusConflict, "Username is already taken", err)
+			return
+		}
 		respondWithError(res, http.StatusInternalServerError, "Couldn't create user", err)
 		return
 	}
 
+	// The account works without a verified email unless chirping requires
+	// one, so a failure here is only logged; the user can ask again.
+	err = cfg.sendEmailVerification(req.Context(), user.ID, user.Email)
+	if err != nil {
+		log.Printf("Unable to send verification email to user %s: %s", user.ID, err)
+	}
+
 	respondWithJSON(res, http.StatusCreated, userRegistrationResponse{
-		User: User{
-			ID:          user.ID,
-			CreatedAt:   user.CreatedAt,
-			UpdatedAt:   user.UpdatedAt,
-			Email:       user.Email,
-			IsChirpyRed: user.IsChirpyRed.Bool,
-		},
+		User: userFromDB(user),
 	})
 }
 
@@ -83,10 +162,9 @@ func (cfg *apiConfig) handleLogin(res http.ResponseWriter, req *http.Request) {
 		Email    string `json:"email"`
 		Password string `json:"password"`
 	}
-	type response struct {
-		User
-		Token        string `json:"token"`
-		RefreshToken string `json:"refresh_token"`
+	type mfaChallengeResponse struct {
+		MFARequired bool   `json:"mfa_required"`
+		MFAToken    string `json:"mfa_token"`
 	}
 	decoder := json.NewDecoder(req.Body)
 	params := userLogin{}
@@ -96,50 +174,128 @@ func (cfg *apiConfig) handleLogin(res http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	_, ipAddress := clientInfo(req)
+	retryAfter, err := cfg.reserveLoginAttempt(req.Context(), params.Email, ipAddress)
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to log in", err)
+		return
+	}
+	if retryAfter > 0 {
+		respondWithLoginLocked(res, retryAfter)
+		return
+	}
+
 	user, err := cfg.db.GetUserByEmail(req.Context(), params.Email)
 	checkPasswordHashErr := auth.CheckPasswordHash(params.Password, user.HashedPassword)
 
 	if err != nil || checkPasswordHashErr != nil {
+		cfg.recordLoginFailure(req.Context(), params.Email, ipAddress, uuid.NullUUID{UUID: user.ID, Valid: err == nil})
 		respondWithError(res, http.StatusUnauthorized, "Incorrect email or password", err)
 		return
 	}
+	cfg.releaseLoginAttempt(req.Context(), params.Email, ipAddress)
+	if user.SuspendedAt.Valid {
+		respondWithError(res, http.StatusForbidden, "This account is suspended", nil)
+		return
+	}
+
+	// Hashes made with an older algorithm or weaker parameters are upgraded
+	// while the password is at hand.
+	if cfg.passwordHasher.NeedsRehash(user.HashedPassword) {
+		cfg.rehashPassword(req.Context(), user, params.Password)
+	}
+
+	// With 2FA on, the password only earns a challenge token that has to be
+	// exchanged at /api/login/2fa along with a code.
+	if user.TotpEnabledAt.Valid {
+		mfaToken, err := cfg.jwtKeys.MakeMFAChallengeJWT(user.ID, mfaChallengeLifetime)
+		if err != nil {
+			respondWithError(res, http.StatusInternalServerError, "unable to get token", err)
+			return
+		}
+		respondWithJSON(res, http.StatusOK, mfaChallengeResponse{
+			MFARequired: true,
+			MFAToken:    mfaToken,
+		})
+		return
+	}
+
+	cfg.completeLogin(res, req, user)
+}
 
-	accessToken, err := auth.MakeJWT(user.ID, cfg.jwtSecret, time.Duration(auth.DefaultExpirationInHours)*time.Hour)
+// rehashPassword stores a new hash of the user's password. It only applies
+// if the stored hash is still the one the password was checked against, so
+// it can't undo a password change made in the meantime. Failures are logged;
+// the old hash keeps working.
+func (cfg *apiConfig) rehashPassword(ctx context.Context, user database.User, password string) {
+	hashedPassword, err := cfg.passwordHasher.Hash(password)
+	if err != nil {
+		log.Printf("Unable to rehash the password of user %s: %s", user.ID, err)
+		return
+	}
+	err = cfg.db.RehashPassword(ctx, database.RehashPasswordParams{
+		NewHash: hashedPassword,
+		ID:      user.ID,
+		OldHash: user.HashedPassword,
+	})
+	if err != nil {
+		log.Printf("Unable to rehash the password of user %s: %s", user.ID, err)
+	}
+}
+
+// completeLogin starts a session for a user who has proven who they are and
+// responds with their access and refresh tokens.
+func (cfg *apiConfig) completeLogin(res http.ResponseWriter, req *http.Request, user database.User) {
+	type response struct {
+		User
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	accessToken, err := cfg.jwtKeys.MakeJWT(user.ID, user.TokenVersion, time.Duration(auth.DefaultExpirationInHours)*time.Hour)
 	if err != nil {
 		respondWithError(res, http.StatusInternalServerError, "unable to get token", err)
 		return
 	}
 
-	refreshToken, _ := auth.MakeRefreshToken()
-	cfg.db.CreateRefreshToken(req.Context(), database.CreateRefreshTokenParams{
-		Token:     refreshToken,
+	userAgent, ipAddress := clientInfo(req)
+	session, err := cfg.db.CreateSession(req.Context(), database.CreateSessionParams{
 		UserID:    user.ID,
-		ExpiresAt: time.Now().AddDate(0, 0, 60),
-		RevokedAt: sql.NullTime{},
+		UserAgent: userAgent,
+		IpAddress: ipAddress,
 	})
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "unable to create a session", err)
+		return
+	}
+
+	refreshToken, err := cfg.issueRefreshToken(req.Context(), user.ID, session.ID)
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "unable to get token", err)
+		return
+	}
+
+	cfg.resetLoginThrottle(req.Context(), user.Email)
 
 	respondWithJSON(res, http.StatusOK, response{
-		Token: accessToken,
-		User: User{
-			ID:          user.ID,
-			CreatedAt:   user.CreatedAt,
-			UpdatedAt:   user.UpdatedAt,
-			Email:       user.Email,
-			IsChirpyRed: user.IsChirpyRed.Bool,
-		},
+		Token:        accessToken,
+		User:         userFromDB(user),
 		RefreshToken: refreshToken,
 	})
 }
 
 func (cfg *apiConfig) handleUpdateUser(res http.ResponseWriter, req *http.Request) {
+	// Email and password are only changed when they are given, so a
+	// request can update just the profile.
 	type parameters struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email    *string `json:"email"`
+		Password *string `json:"password"`
+		profileParameters
 	}
 
-	token, err := auth.GetBearerToken(req.Header)
+	requestAuth, err := cfg.authenticate(req, scopeProfileWrite)
 	if err != nil {
-		respondWithError(res, http.StatusUnauthorized, "Malformed or missing token", err)
+		respondWithError(res, authErrorStatus(err), "invalid token", err)
 		return
 	}
 
@@ -151,84 +307,110 @@ func (cfg *apiConfig) handleUpdateUser(res http.ResponseWriter, req *http.Reques
 		return
 	}
 
-	userId, err := auth.ValidateJWT(token, cfg.jwtSecret)
-	if err != nil {
-		respondWithError(res, http.StatusUnauthorized, "invalid token", err)
-		return
-	}
-
-	user, err := cfg.db.GetUserById(req.Context(), userId)
+	user, err := cfg.db.GetUserById(req.Context(), requestAuth.UserID)
 	if err != nil {
 		respondWithError(res, http.StatusInternalServerError, "unable to retrieve the user", err)
 		return
 	}
 
-	hashedPassword, err := auth.HashPassword(params.Password)
-	if err != nil {
-		respondWithError(res, http.StatusInternalServerError, "unable to hash the password", err)
-		return
-	}
-
-	updatedUserData, err := cfg.db.UpdateUser(req.Context(), database.UpdateUserParams{
-		NewEmail:    params.Email,
-		NewPassword: hashedPassword,
-		OldEmail:    user.Email,
+	profile, err := params.profileParameters.apply(profileColumns{
+		Username:    user.Username,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+		AvatarUrl:   user.AvatarUrl,
 	})
 	if err != nil {
-		respondWithError(res, http.StatusInternalServerError, "unable to update the user's data", err)
+		respondWithError(res, http.StatusBadRequest, err.Error(), err)
 		return
 	}
 
-	respondWithJSON(res, http.StatusOK, User{
-		ID:          updatedUserData.ID,
-		CreatedAt:   updatedUserData.CreatedAt,
-		UpdatedAt:   updatedUserData.UpdatedAt,
-		Email:       updatedUserData.Email,
-		IsChirpyRed: updatedUserData.IsChirpyRed.Bool,
-	})
-}
-
-func (cfg *apiConfig) handleUpgradeToChirpyRed(res http.ResponseWriter, req *http.Request) {
-	polkaKey, err := auth.GetPolkaAPIKey(req.Header)
-	if err != nil {
-		respondWithError(res, http.StatusUnauthorized, "invalid api key", err)
-		return
-	}
-
-	if polkaKey != cfg.polkaAPIKey {
-		respondWithError(res, http.StatusUnauthorized, "api key provided does not match", err)
-		return
-	}
+	// Personal access tokens can edit the profile but not the credentials,
+	// so the email and password are left as they are.
+	hashedPassword := user.HashedPassword
+	passwordChanged := false
+	pendingEmail := user.PendingEmail
+	if requestAuth.PersonalAccessTokenID.Valid {
+		if params.Email != nil || params.Password != nil {
+			respondWithError(res, http.StatusForbidden, "Changing the email or password requires logging in", nil)
+			return
+		}
+	} else {
+		// A new email only becomes pending: it replaces the current one once
+		// the user follows the link sent to it. Asking for the current email
+		// again cancels a pending change.
+		if params.Email != nil {
+			if *params.Email == "" {
+				respondWithError(res, http.StatusBadRequest, "Email can't be empty", nil)
+				return
+			}
+			if *params.Email == user.Email {
+				pendingEmail = sql.NullString{}
+			} else {
+				otherUser, err := cfg.db.GetUserByEmail(req.Context(), *params.Email)
+				if err == nil && otherUser.ID != user.ID {
+					respondWithError(res, http.StatusConflict, "Email is already in use by another account", nil)
+					return
+				}
+				if err != nil && !errors.Is(err, sql.ErrNoRows) {
+					respondWithError(res, http.StatusInternalServerError, "unable to update the user's data", err)
+					return
+				}
+				pendingEmail = sql.NullString{String: *params.Email, Valid: true}
+			}
+		}
 
-	type parameters struct {
-		Event string `json:"event"`
-		Data  struct {
-			UserId uuid.UUID `json:"user_id"`
+		// Leaving the password out keeps the current one.
+		if params.Password != nil && *params.Password == "" {
+			respondWithError(res, http.StatusBadRequest, "Password can't be empty", nil)
+			return
+		}
+		if params.Password != nil && auth.CheckPasswordHash(*params.Password, user.HashedPassword) != nil {
+			if !cfg.checkPasswordPolicy(res, *params.Password) {
+				return
+			}
+			passwordChanged = true
+			hashedPassword, err = cfg.passwordHasher.Hash(*params.Password)
+			if err != nil {
+				respondWithError(res, http.StatusInternalServerError, "unable to hash the password", err)
+				return
+			}
 		}
 	}
 
-	decoder := json.NewDecoder(req.Body)
-	params := parameters{}
-	err = decoder.Decode(&params)
+	updatedUserData, err := cfg.db.UpdateUser(req.Context(), database.UpdateUserParams{
+		PendingEmail: pendingEmail,
+		NewPassword:  hashedPassword,
+		Username:     profile.Username,
+		DisplayName:  profile.DisplayName,
+		Bio:          profile.Bio,
+		AvatarUrl:    profile.AvatarUrl,
+		OldEmail:     user.Email,
+	})
 	if err != nil {
-		respondWithError(res, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		if isUniqueViolation(err, "users_username_key") {
+			respondWithError(res, http.StatusConflict, "Username is already taken", err)
+			return
+		}
+		respondWithError(res, http.StatusInternalServerError, "unable to update the user's data", err)
 		return
 	}
 
-	if params.Event != "user.upgraded" {
-		res.WriteHeader(http.StatusNoContent)
-		return
+	// Whoever knew the old password may still hold tokens, so a new
+	// password signs the user out everywhere, this device included.
+	if passwordChanged {
+		err = cfg.signOutEverywhere(req.Context(), user.ID)
+		if err != nil {
+			respondWithError(res, http.StatusInternalServerError, "unable to sign out the user's sessions", err)
+			return
+		}
 	}
 
-	_, err = cfg.db.UpgradeUserToChirpyRed(req.Context(), params.Data.UserId)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			respondWithError(res, http.StatusNotFound, "Couldn't find user", err)
-			return
+	if pendingEmail.Valid && pendingEmail != user.PendingEmail {
+		err = cfg.sendEmailVerification(req.Context(), user.ID, pendingEmail.String)
+		if err != nil {
+			log.Printf("Unable to send verification email to user %s: %s", user.ID, err)
 		}
-		respondWithError(res, http.StatusInternalServerError, "Unable to upgrade the user", err)
-		return
 	}
 
-	res.WriteHeader(http.StatusNoContent)
+	respondWithJSON(res, http.StatusOK, userFromDB(updatedUserData))
 }
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/nacen-dev/chirpy/internal/database"
+)
+
+// PublicProfile is what anyone can see about a user. It deliberately leaves
+// out the email address and billing status.
+type PublicProfile struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	Username    *string   `json:"username"`
+	DisplayName *string   `json:"display_name"`
+	Bio         *string   `json:"bio"`
+	AvatarURL   *string   `json:"avatar_url"`
+}
+
+func publicProfileFromDB(user database.User) PublicProfile {
+	return PublicProfile{
+		ID:          user.ID,
+		CreatedAt:   user.CreatedAt,
+		Username:    nullStringPtr(user.Username),
+		DisplayName: nullStringPtr(user.DisplayName),
+		Bio:         nullStringPtr(user.Bio),
+		AvatarURL:   nullStringPtr(user.AvatarUrl),
+	}
+}
+
+const (
+	maxDisplayNameLength = 50
+	maxBioLength         = 160
+	maxAvatarURLLength   = 2048
+)
+
+// profileParameters are the profile fields accepted when creating or
+// updating a user. A missing field leaves the current value alone and an
+// empty string clears it.
+type profileParameters struct {
+	Username    *string `json:"username"`
+	DisplayName *string `json:"display_name"`
+	Bio         *string `json:"bio"`
+	AvatarURL   *string `json:"avatar_url"`
+}
+
+type profileColumns struct {
+	Username    sql.NullString
+	DisplayName sql.NullString
+	Bio         sql.NullString
+	AvatarUrl   sql.NullString
+}
+
+// apply validates the parameters and returns the profile columns that result
+// from applying them on top of current.
+func (params profileParameters) apply(current profileColumns) (profileColumns, error) {
+	updated := current
+
+	if params.Username != nil {
+		if *params.Username != "" && !isUsernameValid(*params.Username) {
+			return profileColumns{}, errors.New("username must be 3 to 30 letters, digits or underscores")
+		}
+		updated.Username = optionalString(*params.Username)
+	}
+
+	if params.DisplayName != nil {
+		if utf8.RuneCountInString(*params.DisplayName) > maxDisplayNameLength {
+			return profileColumns{}, errors.New("display_name must be at most 50 characters")
+		}
+		updated.DisplayName = optionalString(*params.DisplayName)
+	}
+
+	if params.Bio != nil {
+		if utf8.RuneCountInString(*params.Bio) > maxBioLength {
+			return profileColumns{}, errors.New("bio must be at most 160 characters")
+		}
+		updated.Bio = optionalString(*params.Bio)
+	}
+
+	if params.AvatarURL != nil {
+		if *params.AvatarURL != "" && !isAvatarURLValid(*params.AvatarURL) {
+			return profileColumns{}, errors.New("avatar_url must be an absolute http or https URL")
+		}
+		updated.AvatarUrl = optionalString(*params.AvatarURL)
+	}
+
+	return updated, nil
+}
+
+func optionalString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func isAvatarURLValid(avatarURL string) bool {
+	if len(avatarURL) > maxAvatarURLLength {
+		return false
+	}
+	parsedURL, err := url.Parse(avatarURL)
+	if err != nil {
+		return false
+	}
+	return (parsedURL.Scheme == "http" || parsedURL.Scheme == "https") && parsedURL.Host != ""
+}
+
+// getUserByIdOrUsername looks a user up by either identifier. Usernames are
+// too short to parse as UUIDs, so the two can't be confused.
+func (cfg *apiConfig) getUserByIdOrUsername(req *http.Request, idOrUsername string) (database.User, error) {
+	userId, err := uuid.Parse(idOrUsername)
+	if err == nil {
+		return cfg.db.GetUserById(req.Context(), userId)
+	}
+	return cfg.db.GetUserByUsername(req.Context(), idOrUsername)
+}
+
+func (cfg *apiConfig) handleGetUserProfile(res http.ResponseWriter, req *http.Request) {
+	user, err := cfg.getUserByIdOrUsername(req, req.PathValue("userIdOrUsername"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(res, http.StatusNotFound, "Couldn't find user", err)
+			return
+		}
+		respondWithError(res, http.StatusInternalServerError, "Unable to get the user", err)
+		return
+	}
+
+	respondWithJSON(res, http.StatusOK, publicProfileFromDB(user))
+}
@@ -1,27 +1,184 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"log"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/nacen-dev/chirpy/internal/auth"
 	"github.com/nacen-dev/chirpy/internal/database"
 )
 
 type Chirp struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	UserID    uuid.UUID `json:"user_id"`
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Body      string     `json:"body"`
+	UserID    uuid.UUID  `json:"user_id"`
+	InReplyTo *uuid.UUID `json:"in_reply_to"`
+	Edited    bool       `json:"edited"`
+	LikeCount int64      `json:"like_count"`
+	LikedByMe bool       `json:"liked_by_me"`
+	// RechirpOf and QuotedChirp embed the original chirp. QuoteDeleted marks
+	// a quote whose original has since been deleted.
+	RechirpOf    *Chirp         `json:"rechirp_of"`
+	RechirpCount int64          `json:"rechirp_count"`
+	QuotedChirp  *Chirp         `json:"quoted_chirp"`
+	QuoteDeleted bool           `json:"quote_deleted"`
+	Mentions     []ChirpMention `json:"mentions"`
+}
+
+// ChirpMention is an @handle in a chirp body that belongs to a user. Start
+// and End are rune offsets into the body, with End exclusive.
+type ChirpMention struct {
+	UserID   uuid.UUID `json:"user_id"`
+	Username string    `json:"username"`
+	Start    int       `json:"start"`
+	End      int       `json:"end"`
+}
+
+func chirpFromDB(dbChirp database.Chirp) Chirp {
+	chirp := Chirp{
+		ID:        dbChirp.ID,
+		CreatedAt: dbChirp.CreatedAt,
+		UpdatedAt: dbChirp.UpdatedAt,
+		Body:      dbChirp.Body,
+		UserID:    dbChirp.UserID,
+		// Chirps are created with identical timestamps and only edits bump
+		// updated_at.
+		Edited:       dbChirp.UpdatedAt.After(dbChirp.CreatedAt),
+		QuoteDeleted: dbChirp.QuoteDeleted,
+		Mentions:     []ChirpMention{},
+	}
+	if dbChirp.InReplyTo.Valid {
+		chirp.InReplyTo = &dbChirp.InReplyTo.UUID
+	}
+	return chirp
+}
+
+// chirpResponses converts chirps for a response, fills in their engagement
+// counters and mentions and embeds the originals of rechirps and quotes, all
+// with a fixed number of queries per page rather than per chirp. viewerId is
+// uuid.Nil for anonymous requests.
+func (cfg *apiConfig) chirpResponses(ctx context.Context, dbChirps []database.Chirp, viewerId uuid.UUID) ([]Chirp, error) {
+	chirps, err := cfg.chirpsWithEngagement(ctx, dbChirps, viewerId)
+	if err != nil {
+		return nil, err
+	}
+
+	originalIds := []uuid.UUID{}
+	for _, dbChirp := range dbChirps {
+		if dbChirp.RechirpOf.Valid {
+			originalIds = append(originalIds, dbChirp.RechirpOf.UUID)
+		}
+		if dbChirp.QuoteOf.Valid {
+			originalIds = append(originalIds, dbChirp.QuoteOf.UUID)
+		}
+	}
+	if len(originalIds) == 0 {
+		return chirps, nil
+	}
+
+	dbOriginals, err := cfg.db.GetChirpsByIds(ctx, originalIds)
+	if err != nil {
+		return nil, err
+	}
+	// Originals are embedded one level deep only.
+	originals, err := cfg.chirpsWithEngagement(ctx, dbOriginals, viewerId)
+	if err != nil {
+		return nil, err
+	}
+	originalsById := map[uuid.UUID]*Chirp{}
+	for index := range originals {
+		originalsById[originals[index].ID] = &originals[index]
+	}
+
+	for index, dbChirp := range dbChirps {
+		if dbChirp.RechirpOf.Valid {
+			chirps[index].RechirpOf = originalsById[dbChirp.RechirpOf.UUID]
+		}
+		if dbChirp.QuoteOf.Valid {
+			chirps[index].QuotedChirp = originalsById[dbChirp.QuoteOf.UUID]
+		}
+	}
+	return chirps, nil
+}
+
+func (cfg *apiConfig) chirpsWithEngagement(ctx context.Context, dbChirps []database.Chirp, viewerId uuid.UUID) ([]Chirp, error) {
+	chirps := []Chirp{}
+	if len(dbChirps) == 0 {
+		return chirps, nil
+	}
+
+	chirpIds := make([]uuid.UUID, 0, len(dbChirps))
+	for _, dbChirp := range dbChirps {
+		chirpIds = append(chirpIds, dbChirp.ID)
+	}
+
+	likeStats, err := cfg.db.GetChirpLikeStats(ctx, database.GetChirpLikeStatsParams{
+		ViewerID: viewerId,
+		ChirpIds: chirpIds,
+	})
+	if err != nil {
+		return nil, err
+	}
+	likes := map[uuid.UUID]database.GetChirpLikeStatsRow{}
+	for _, stats := range likeStats {
+		likes[stats.ChirpID] = stats
+	}
+
+	rechirpCounts, err := cfg.db.GetRechirpCounts(ctx, chirpIds)
+	if err != nil {
+		return nil, err
+	}
+	rechirps := map[uuid.UUID]int64{}
+	for _, count := range rechirpCounts {
+		rechirps[count.ChirpID] = count.RechirpCount
+	}
+
+	chirpMentions, err := cfg.db.GetChirpMentions(ctx, chirpIds)
+	if err != nil {
+		return nil, err
+	}
+	mentions := map[uuid.UUID][]ChirpMention{}
+	for _, mention := range chirpMentions {
+		mentions[mention.ChirpID] = append(mentions[mention.ChirpID], ChirpMention{
+			UserID:   mention.UserID,
+			Username: mention.Username.String,
+			Start:    int(mention.StartOffset),
+			End:      int(mention.EndOffset),
+		})
+	}
+
+	for _, dbChirp := range dbChirps {
+		chirp := chirpFromDB(dbChirp)
+		chirp.LikeCount = likes[dbChirp.ID].LikeCount
+		chirp.LikedByMe = likes[dbChirp.ID].LikedByViewer
+		chirp.RechirpCount = rechirps[dbChirp.ID]
+		if chirpMentions, ok := mentions[dbChirp.ID]; ok {
+			chirp.Mentions = chirpMentions
+		}
+		chirps = append(chirps, chirp)
+	}
+	return chirps, nil
+}
+
+func (cfg *apiConfig) chirpResponse(ctx context.Context, dbChirp database.Chirp, viewerId uuid.UUID) (Chirp, error) {
+	chirps, err := cfg.chirpResponses(ctx, []database.Chirp{dbChirp}, viewerId)
+	if err != nil {
+		return Chirp{}, err
+	}
+	return chirps[0], nil
 }
 
 func (cfg *apiConfig) handleCreateChirp(res http.ResponseWriter, req *http.Request) {
 	type parameters struct {
-		Body string `json:"body"`
+		Body      string     `json:"body"`
+		InReplyTo *uuid.UUID `json:"in_reply_to"`
+		QuoteOf   *uuid.UUID `json:"quote_of"`
 	}
 
 	decoder := json.NewDecoder(req.Body)
@@ -33,16 +190,19 @@ func (cfg *apiConfig) handleCreateChirp(res http.ResponseWriter, req *http.Reque
 		return
 	}
 
-	token, err := auth.GetBearerToken(req.Header)
+	userIdFromJWT, err := cfg.authenticatedUserID(req, scopeChirpsWrite)
 	if err != nil {
-		respondWithError(res, http.StatusUnauthorized, "Unable to get bearer token", err)
+		respondWithError(res, authErrorStatus(err), "Invalid jwt", err)
 		return
 	}
 
-	userIdFromJWT, err := auth.ValidateJWT(token, cfg.jwtSecret)
-
+	allowed, err := cfg.canChirp(req.Context(), userIdFromJWT)
 	if err != nil {
-		respondWithError(res, http.StatusUnauthorized, "Invalid jwt", err)
+		respondWithError(res, http.StatusInternalServerError, "unable to retrieve the user", err)
+		return
+	}
+	if !allowed {
+		respondWithError(res, http.StatusForbidden, "Verify your email address before chirping", nil)
 		return
 	}
 
@@ -51,30 +211,60 @@ func (cfg *apiConfig) handleCreateChirp(res http.ResponseWriter, req *http.Reque
 		return
 	}
 
+	inReplyTo := uuid.NullUUID{}
+	if params.InReplyTo != nil {
+		parentChirp, err := cfg.getOriginalChirp(req.Context(), *params.InReplyTo)
+		if err != nil {
+			respondWithError(res, http.StatusBadRequest, "The chirp being replied to doesn't exist", err)
+			return
+		}
+		inReplyTo = uuid.NullUUID{UUID: parentChirp.ID, Valid: true}
+	}
+
+	quoteOf := uuid.NullUUID{}
+	if params.QuoteOf != nil {
+		quotedChirp, err := cfg.getOriginalChirp(req.Context(), *params.QuoteOf)
+		if err != nil {
+			respondWithError(res, http.StatusBadRequest, "The chirp being quoted doesn't exist", err)
+			return
+		}
+		quoteOf = uuid.NullUUID{UUID: quotedChirp.ID, Valid: true}
+	}
+
 	chirp, err := cfg.db.CreateChirp(req.Context(), database.CreateChirpParams{
-		Body:   params.Body,
-		UserID: userIdFromJWT,
+		Body:      params.Body,
+		UserID:    userIdFromJWT,
+		InReplyTo: inReplyTo,
+		QuoteOf:   quoteOf,
 	})
 
-	profaneWords := map[string]struct{}{
-		"kerfuffle": {},
-		"sharbert":  {},
-		"fornax":    {},
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "unable to create the chirp", nil)
+		return
+	}
+
+	// The chirp itself is stored at this point, so a failure here only
+	// leaves it out of tag and mention feeds.
+	err = cfg.indexChirpEntities(req.Context(), chirp)
+	if err != nil {
+		log.Printf("Unable to index chirp %s: %s", chirp.ID, err)
 	}
 
+	response, err := cfg.chirpResponse(req.Context(), chirp, userIdFromJWT)
 	if err != nil {
-		respondWithError(res, http.StatusInternalServerError, "unable to create the chirp", nil)
+		respondWithError(res, http.StatusInternalServerError, "unable to create the chirp", err)
 		return
 	}
+	response.Body = cleanProfaneWords(chirp.Body, profaneWords)
+	response.Mentions = relocateMentions(chirp.Body, response.Body, response.Mentions)
+	respondWithJSON(res, http.StatusCreated, response)
 
-	respondWithJSON(res, http.StatusCreated, Chirp{
-		ID:        chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		Body:      cleanProfaneWords(chirp.Body, profaneWords),
-		UserID:    chirp.UserID,
-	})
+}
 
+var profaneWords = map[string]struct{}{
+	"kerfuffle": {},
+	"sharbert":  {},
+	"fornax":    {},
 }
 
 func isChirpValid(chirp string) bool {
@@ -108,26 +298,53 @@ func (cfg *apiConfig) handleGetChirps(res http.ResponseWriter, req *http.Request
 		}
 	}
 
-	chirps, chirpsError := cfg.db.GetChirps(req.Context(), database.GetChirpsParams{
-		AuthorID: parsed_author_id,
-		OrderBy:  sortingOrder,
-	})
+	if sortingOrder != "" && sortingOrder != "asc" && sortingOrder != "desc" {
+		respondWithError(res, http.StatusBadRequest, "sort must be either asc or desc", nil)
+		return
+	}
 
-	if chirpsError != nil {
-		respondWithError(res, http.StatusInternalServerError, "Unable to get chirps", err)
+	page, err := parsePageParams(req)
+	if err != nil {
+		respondWithError(res, http.StatusBadRequest, err.Error(), err)
 		return
 	}
 
-	response := []Chirp{}
-	for _, dbChirp := range chirps {
-		response = append(response, Chirp{
-			ID:        dbChirp.ID,
-			CreatedAt: dbChirp.CreatedAt,
-			UpdatedAt: dbChirp.UpdatedAt,
-			Body:      dbChirp.Body,
-			UserID:    dbChirp.UserID,
+	// One extra row tells us whether there is a next page.
+	var chirps []database.Chirp
+	if sortingOrder == "desc" {
+		chirps, err = cfg.db.GetChirpsDesc(req.Context(), database.GetChirpsDescParams{
+			AuthorID:        parsed_author_id,
+			HasCursor:       page.HasCursor,
+			CursorCreatedAt: page.Cursor.CreatedAt,
+			CursorID:        page.Cursor.ID,
+			PageLimit:       page.Limit + 1,
+		})
+	} else {
+		chirps, err = cfg.db.GetChirpsAsc(req.Context(), database.GetChirpsAscParams{
+			AuthorID:        parsed_author_id,
+			HasCursor:       page.HasCursor,
+			CursorCreatedAt: page.Cursor.CreatedAt,
+			CursorID:        page.Cursor.ID,
+			PageLimit:       page.Limit + 1,
 		})
 	}
+
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to get chirps", err)
+		return
+	}
+
+	if len(chirps) > int(page.Limit) {
+		chirps = chirps[:page.Limit]
+		last := chirps[len(chirps)-1]
+		setNextPageLink(res, req, pageCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+
+	response, err := cfg.chirpResponses(req.Context(), chirps, cfg.optionalUserID(req))
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to get chirps", err)
+		return
+	}
 	respondWithJSON(res, http.StatusOK, response)
 }
 
@@ -144,13 +361,12 @@ func (cfg *apiConfig) handleGetChirpById(res http.ResponseWriter, req *http.Requ
 		return
 	}
 
-	respondWithJSON(res, http.StatusOK, Chirp{
-		ID:        dbChirp.ID,
-		CreatedAt: dbChirp.CreatedAt,
-		UpdatedAt: dbChirp.UpdatedAt,
-		Body:      dbChirp.Body,
-		UserID:    dbChirp.UserID,
-	})
+	response, err := cfg.chirpResponse(req.Context(), dbChirp, cfg.optionalUserID(req))
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Couldn't retrieve chirp", err)
+		return
+	}
+	respondWithJSON(res, http.StatusOK, response)
 }
 
 func (cfg *apiConfig) handleDeleteChirpById(res http.ResponseWriter, req *http.Request) {
@@ -160,16 +376,58 @@ func (cfg *apiConfig) handleDeleteChirpById(res http.ResponseWriter, req *http.R
 		return
 	}
 
-	accessToken, err := auth.GetBearerToken(req.Header)
+	userIdFromJWT, err := cfg.authenticatedUserID(req, scopeChirpsWrite)
+	if err != nil {
+		respondWithError(res, authErrorStatus(err), "Invalid jwt", err)
+		return
+	}
+
+	chirp, err := cfg.db.GetChirpById(req.Context(), chirpId)
 	if err != nil {
-		respondWithError(res, http.StatusUnauthorized, "No token found", err)
+		respondWithError(res, http.StatusNotFound, "Chirp not found", err)
 		return
 	}
 
-	userIdFromJWT, err := auth.ValidateJWT(accessToken, cfg.jwtSecret)
+	if chirp.UserID != userIdFromJWT {
+		respondWithError(res, http.StatusForbidden, "Unauthorized", err)
+		return
+	}
 
+	err = cfg.db.DeleteChirpById(req.Context(), chirp.ID)
 	if err != nil {
-		respondWithError(res, http.StatusUnauthorized, "Invalid jwt", err)
+		respondWithError(res, http.StatusInternalServerError, "Unable to delete chirp...", err)
+		return
+	}
+	res.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handleUpdateChirp(res http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Body string `json:"body"`
+	}
+
+	chirpId, err := uuid.Parse(req.PathValue("chirpId"))
+	if err != nil {
+		respondWithError(res, http.StatusBadRequest, "Chirp Id is missing", err)
+		return
+	}
+
+	userIdFromJWT, err := cfg.authenticatedUserID(req, scopeChirpsWrite)
+	if err != nil {
+		respondWithError(res, authErrorStatus(err), "Invalid jwt", err)
+		return
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		return
+	}
+
+	if !isChirpValid(params.Body) {
+		respondWithError(res, http.StatusBadRequest, "Chirp is too long", nil)
 		return
 	}
 
@@ -184,10 +442,34 @@ func (cfg *apiConfig) handleDeleteChirpById(res http.ResponseWriter, req *http.R
 		return
 	}
 
-	err = cfg.db.DeleteChirpById(req.Context(), chirp.ID)
+	if chirp.RechirpOf.Valid {
+		respondWithError(res, http.StatusBadRequest, "Rechirps can't be edited", nil)
+		return
+	}
+
+	updatedChirp := chirp
+	if chirp.Body != params.Body {
+		updatedChirp, err = cfg.db.UpdateChirpBody(req.Context(), database.UpdateChirpBodyParams{
+			ID:   chirp.ID,
+			Body: params.Body,
+		})
+		if err != nil {
+			respondWithError(res, http.StatusInternalServerError, "Unable to update chirp", err)
+			return
+		}
+
+		err = cfg.indexChirpEntities(req.Context(), updatedChirp)
+		if err != nil {
+			log.Printf("Unable to index chirp %s: %s", updatedChirp.ID, err)
+		}
+	}
+
+	response, err := cfg.chirpResponse(req.Context(), updatedChirp, userIdFromJWT)
 	if err != nil {
-		respondWithError(res, http.StatusInternalServerError, "Unable to delete chirp...", err)
+		respondWithError(res, http.StatusInternalServerError, "Unable to update chirp", err)
 		return
 	}
-	res.WriteHeader(http.StatusNoContent)
+	response.Body = cleanProfaneWords(updatedChirp.Body, profaneWords)
+	response.Mentions = relocateMentions(updatedChirp.Body, response.Body, response.Mentions)
+	respondWithJSON(res, http.StatusOK, response)
 }
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/nacen-dev/chirpy/internal/database"
+)
+
+func (cfg *apiConfig) handleLikeChirp(res http.ResponseWriter, req *http.Request) {
+	chirpId, err := uuid.Parse(req.PathValue("chirpId"))
+	if err != nil {
+		respondWithError(res, http.StatusBadRequest, "Invalid chirp id", err)
+		return
+	}
+
+	userIdFromJWT, err := cfg.authenticatedUserID(req, scopeChirpsWrite)
+	if err != nil {
+		respondWithError(res, authErrorStatus(err), "Invalid or missing token", err)
+		return
+	}
+
+	_, err = cfg.db.GetChirpById(req.Context(), chirpId)
+	if err != nil {
+		respondWithError(res, http.StatusNotFound, "Chirp not found", err)
+		return
+	}
+
+	err = cfg.db.LikeChirp(req.Context(), database.LikeChirpParams{
+		UserID:  userIdFromJWT,
+		ChirpID: chirpId,
+	})
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to like the chirp", err)
+		return
+	}
+	res.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handleUnlikeChirp(res http.ResponseWriter, req *http.Request) {
+	chirpId, err := uuid.Parse(req.PathValue("chirpId"))
+	if err != nil {
+		respondWithError(res, http.StatusBadRequest, "Invalid chirp id", err)
+		return
+	}
+
+	userIdFromJWT, err := cfg.authenticatedUserID(req, scopeChirpsWrite)
+	if err != nil {
+		respondWithError(res, authErrorStatus(err), "Invalid or missing token", err)
+		return
+	}
+
+	err = cfg.db.UnlikeChirp(req.Context(), database.UnlikeChirpParams{
+		UserID:  userIdFromJWT,
+		ChirpID: chirpId,
+	})
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to unlike the chirp", err)
+		return
+	}
+	res.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handleGetUserLikes(res http.ResponseWriter, req *http.Request) {
+	userId, err := uuid.Parse(req.PathValue("userId"))
+	if err != nil {
+		respondWithError(res, http.StatusBadRequest, "Invalid user id", err)
+		return
+	}
+
+	page, err := parsePageParams(req)
+	if err != nil {
+		respondWithError(res, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	likes, err := cfg.db.GetLikedChirps(req.Context(), database.GetLikedChirpsParams{
+		UserID:          userId,
+		HasCursor:       page.HasCursor,
+		CursorCreatedAt: page.Cursor.CreatedAt,
+		CursorID:        page.Cursor.ID,
+		PageLimit:       page.Limit + 1,
+	})
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to get liked chirps", err)
+		return
+	}
+
+	if len(likes) > int(page.Limit) {
+		likes = likes[:page.Limit]
+		last := likes[len(likes)-1]
+		setNextPageLink(res, req, pageCursor{CreatedAt: last.LikedAt, ID: last.Chirp.ID})
+	}
+
+	dbChirps := []database.Chirp{}
+	for _, like := range likes {
+		dbChirps = append(dbChirps, like.Chirp)
+	}
+	response, err := cfg.chirpResponses(req.Context(), dbChirps, cfg.optionalUserID(req))
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to get liked chirps", err)
+		return
+	}
+	respondWithJSON(res, http.StatusOK, response)
+}
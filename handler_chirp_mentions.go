@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/nacen-dev/chirpy/internal/database"
+)
+
+func (cfg *apiConfig) handleGetMentions(res http.ResponseWriter, req *http.Request) {
+	userId, err := cfg.authenticatedUserID(req, scopeChirpsRead)
+	if err != nil {
+		respondWithError(res, authErrorStatus(err), "Invalid jwt", err)
+		return
+	}
+
+	page, err := parsePageParams(req)
+	if err != nil {
+		respondWithError(res, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	chirps, err := cfg.db.GetMentionedChirps(req.Context(), database.GetMentionedChirpsParams{
+		UserID:          userId,
+		HasCursor:       page.HasCursor,
+		CursorCreatedAt: page.Cursor.CreatedAt,
+		CursorID:        page.Cursor.ID,
+		PageLimit:       page.Limit + 1,
+	})
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to get mentions", err)
+		return
+	}
+
+	if len(chirps) > int(page.Limit) {
+		chirps = chirps[:page.Limit]
+		last := chirps[len(chirps)-1]
+		setNextPageLink(res, req, pageCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+
+	response, err := cfg.chirpResponses(req.Context(), chirps, userId)
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to get mentions", err)
+		return
+	}
+	respondWithJSON(res, http.StatusOK, response)
+}
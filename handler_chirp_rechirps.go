@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/nacen-dev/chirpy/internal/database"
+)
+
+// getOriginalChirp looks up a chirp, following a rechirp to the chirp it
+// re-shares. Replies, quotes and rechirps always point at the original.
+func (cfg *apiConfig) getOriginalChirp(ctx context.Context, chirpId uuid.UUID) (database.Chirp, error) {
+	chirp, err := cfg.db.GetChirpById(ctx, chirpId)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	if chirp.RechirpOf.Valid {
+		return cfg.db.GetChirpById(ctx, chirp.RechirpOf.UUID)
+	}
+	return chirp, nil
+}
+
+func (cfg *apiConfig) handleRechirp(res http.ResponseWriter, req *http.Request) {
+	chirpId, err := uuid.Parse(req.PathValue("chirpId"))
+	if err != nil {
+		respondWithError(res, http.StatusBadRequest, "Invalid chirp id", err)
+		return
+	}
+
+	userIdFromJWT, err := cfg.authenticatedUserID(req, scopeChirpsWrite)
+	if err != nil {
+		respondWithError(res, authErrorStatus(err), "Invalid or missing token", err)
+		return
+	}
+
+	allowed, err := cfg.canChirp(req.Context(), userIdFromJWT)
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "unable to retrieve the user", err)
+		return
+	}
+	if !allowed {
+		respondWithError(res, http.StatusForbidden, "Verify your email address before chirping", nil)
+		return
+	}
+
+	original, err := cfg.getOriginalChirp(req.Context(), chirpId)
+	if err != nil {
+		respondWithError(res, http.StatusNotFound, "Chirp not found", err)
+		return
+	}
+
+	rechirpOf := uuid.NullUUID{UUID: original.ID, Valid: true}
+	status := http.StatusCreated
+	rechirp, err := cfg.db.CreateRechirp(req.Context(), database.CreateRechirpParams{
+		UserID:    userIdFromJWT,
+		RechirpOf: rechirpOf,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		// Already rechirped, hand back the existing one.
+		status = http.StatusOK
+		rechirp, err = cfg.db.GetRechirp(req.Context(), database.GetRechirpParams{
+			UserID:    userIdFromJWT,
+			RechirpOf: rechirpOf,
+		})
+	}
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to rechirp", err)
+		return
+	}
+
+	response, err := cfg.chirpResponse(req.Context(), rechirp, userIdFromJWT)
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to rechirp", err)
+		return
+	}
+	respondWithJSON(res, status, response)
+}
+
+func (cfg *apiConfig) handleUndoRechirp(res http.ResponseWriter, req *http.Request) {
+	chirpId, err := uuid.Parse(req.PathValue("chirpId"))
+	if err != nil {
+		respondWithError(res, http.StatusBadRequest, "Invalid chirp id", err)
+		return
+	}
+
+	userIdFromJWT, err := cfg.authenticatedUserID(req, scopeChirpsWrite)
+	if err != nil {
+		respondWithError(res, authErrorStatus(err), "Invalid or missing token", err)
+		return
+	}
+
+	original, err := cfg.getOriginalChirp(req.Context(), chirpId)
+	if err != nil {
+		respondWithError(res, http.StatusNotFound, "Chirp not found", err)
+		return
+	}
+
+	err = cfg.db.DeleteRechirp(req.Context(), database.DeleteRechirpParams{
+		UserID:    userIdFromJWT,
+		RechirpOf: uuid.NullUUID{UUID: original.ID, Valid: true},
+	})
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to undo the rechirp", err)
+		return
+	}
+	res.WriteHeader(http.StatusNoContent)
+}
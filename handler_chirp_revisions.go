@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ChirpRevision struct {
+	ID         uuid.UUID `json:"id"`
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"created_at"`
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+func (cfg *apiConfig) handleGetChirpRevisions(res http.ResponseWriter, req *http.Request) {
+	chirpId, err := uuid.Parse(req.PathValue("chirpId"))
+	if err != nil {
+		respondWithError(res, http.StatusBadRequest, "Invalid chirp id", err)
+		return
+	}
+
+	_, err = cfg.db.GetChirpById(req.Context(), chirpId)
+	if err != nil {
+		respondWithError(res, http.StatusNotFound, "Couldn't retrieve chirp", err)
+		return
+	}
+
+	revisions, err := cfg.db.GetChirpRevisions(req.Context(), chirpId)
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to get the chirp's revisions", err)
+		return
+	}
+
+	response := []ChirpRevision{}
+	for _, revision := range revisions {
+		response = append(response, ChirpRevision{
+			ID:         revision.ID,
+			Body:       revision.Body,
+			CreatedAt:  revision.CreatedAt,
+			ReplacedAt: revision.ReplacedAt,
+		})
+	}
+	respondWithJSON(res, http.StatusOK, response)
+}
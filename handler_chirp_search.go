@@ -0,0 +1,126 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/nacen-dev/chirpy/internal/database"
+)
+
+type ChirpSearchResult struct {
+	Chirp
+	Rank float32 `json:"rank"`
+	// Snippet is HTML: matches are wrapped in <mark> and the rest of the
+	// body is escaped.
+	Snippet string `json:"snippet"`
+}
+
+func (cfg *apiConfig) handleSearchChirps(res http.ResponseWriter, req *http.Request) {
+	query := buildSearchQuery(req.URL.Query().Get("q"))
+	if query == "" {
+		respondWithError(res, http.StatusBadRequest, "q must contain at least one word", nil)
+		return
+	}
+
+	author_id := req.URL.Query().Get("author_id")
+	var parsed_author_id uuid.UUID
+	var err error
+
+	if author_id != "" {
+		parsed_author_id, err = uuid.Parse(author_id)
+		if err != nil {
+			respondWithError(res, http.StatusBadRequest, "Invalid author id", err)
+			return
+		}
+	}
+
+	page, err := parsePageParams(req)
+	if err != nil {
+		respondWithError(res, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	results, err := cfg.db.SearchChirps(req.Context(), database.SearchChirpsParams{
+		Query:           query,
+		AuthorID:        parsed_author_id,
+		HasCursor:       page.HasCursor,
+		CursorRank:      page.Cursor.Rank,
+		CursorCreatedAt: page.Cursor.CreatedAt,
+		CursorID:        page.Cursor.ID,
+		PageLimit:       page.Limit + 1,
+	})
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to search chirps", err)
+		return
+	}
+
+	if len(results) > int(page.Limit) {
+		results = results[:page.Limit]
+		last := results[len(results)-1]
+		setNextPageLink(res, req, pageCursor{CreatedAt: last.Chirp.CreatedAt, ID: last.Chirp.ID, Rank: last.Rank})
+	}
+
+	dbChirps := []database.Chirp{}
+	for _, result := range results {
+		dbChirps = append(dbChirps, result.Chirp)
+	}
+	chirps, err := cfg.chirpResponses(req.Context(), dbChirps, cfg.optionalUserID(req))
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to search chirps", err)
+		return
+	}
+
+	response := []ChirpSearchResult{}
+	for index, result := range results {
+		response = append(response, ChirpSearchResult{
+			Chirp:   chirps[index],
+			Rank:    result.Rank,
+			Snippet: result.Snippet,
+		})
+	}
+	respondWithJSON(res, http.StatusOK, response)
+}
+
+// buildSearchQuery turns user input into a to_tsquery expression. Every word
+// is required, "quoted words" must appear next to each other and a trailing
+// * makes a word match as a prefix. Anything else is dropped so the result is
+// always valid tsquery syntax; an empty result means there was nothing to
+// search for.
+func buildSearchQuery(q string) string {
+	terms := []string{}
+	for index, segment := range strings.Split(q, `"`) {
+		words := searchWords(segment)
+		if len(words) == 0 {
+			continue
+		}
+		// Odd segments sit between a pair of quotes.
+		if index%2 == 1 && len(words) > 1 {
+			terms = append(terms, "("+strings.Join(words, " <-> ")+")")
+			continue
+		}
+		terms = append(terms, words...)
+	}
+	return strings.Join(terms, " & ")
+}
+
+func searchWords(s string) []string {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '*'
+	})
+
+	words := []string{}
+	for _, field := range fields {
+		isPrefix := strings.HasSuffix(field, "*")
+		word := strings.ReplaceAll(field, "*", "")
+		if word == "" {
+			continue
+		}
+		if isPrefix {
+			word += ":*"
+		}
+		words = append(words, word)
+	}
+	return words
+}
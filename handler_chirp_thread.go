@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/nacen-dev/chirpy/internal/database"
+)
+
+const (
+	maxThreadDepth   = 10
+	maxThreadReplies = 500
+)
+
+type ThreadChirp struct {
+	Chirp
+	ReplyCount int64         `json:"reply_count"`
+	Replies    []ThreadChirp `json:"replies,omitempty"`
+}
+
+func (cfg *apiConfig) handleGetChirpThread(res http.ResponseWriter, req *http.Request) {
+	chirpId, err := uuid.Parse(req.PathValue("chirpId"))
+	if err != nil {
+		respondWithError(res, http.StatusBadRequest, "Invalid chirp id", err)
+		return
+	}
+
+	dbChirp, err := cfg.db.GetChirpById(req.Context(), chirpId)
+	if err != nil {
+		respondWithError(res, http.StatusNotFound, "Couldn't retrieve chirp", err)
+		return
+	}
+
+	ancestors, err := cfg.db.GetChirpAncestors(req.Context(), chirpId)
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to get the thread", err)
+		return
+	}
+
+	replies, err := cfg.db.GetChirpReplies(req.Context(), database.GetChirpRepliesParams{
+		ChirpID:    chirpId,
+		MaxDepth:   maxThreadDepth,
+		MaxReplies: maxThreadReplies,
+	})
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to get the thread", err)
+		return
+	}
+
+	threadChirps := append([]database.Chirp{dbChirp}, ancestors...)
+	threadChirps = append(threadChirps, replies...)
+	chirps, err := cfg.chirpResponses(req.Context(), threadChirps, cfg.optionalUserID(req))
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to get the thread", err)
+		return
+	}
+
+	chirpIds := []uuid.UUID{}
+	chirpsById := map[uuid.UUID]Chirp{}
+	for _, chirp := range chirps {
+		chirpIds = append(chirpIds, chirp.ID)
+		chirpsById[chirp.ID] = chirp
+	}
+	counts, err := cfg.db.GetReplyCounts(req.Context(), chirpIds)
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to count replies", err)
+		return
+	}
+	replyCounts := map[uuid.UUID]int64{}
+	for _, count := range counts {
+		replyCounts[count.ChirpID] = count.ReplyCount
+	}
+
+	// Replies come back oldest first, so every child list stays in order.
+	children := map[uuid.UUID][]uuid.UUID{}
+	for _, reply := range replies {
+		children[reply.InReplyTo.UUID] = append(children[reply.InReplyTo.UUID], reply.ID)
+	}
+
+	type response struct {
+		Ancestors []ThreadChirp `json:"ancestors"`
+		Chirp     ThreadChirp   `json:"chirp"`
+	}
+	thread := response{
+		Ancestors: []ThreadChirp{},
+		Chirp:     buildThread(dbChirp.ID, chirpsById, children, replyCounts),
+	}
+	for _, ancestor := range ancestors {
+		thread.Ancestors = append(thread.Ancestors, ThreadChirp{
+			Chirp:      chirpsById[ancestor.ID],
+			ReplyCount: replyCounts[ancestor.ID],
+		})
+	}
+	respondWithJSON(res, http.StatusOK, thread)
+}
+
+func buildThread(chirpId uuid.UUID, chirpsById map[uuid.UUID]Chirp, children map[uuid.UUID][]uuid.UUID, replyCounts map[uuid.UUID]int64) ThreadChirp {
+	node := ThreadChirp{
+		Chirp:      chirpsById[chirpId],
+		ReplyCount: replyCounts[chirpId],
+	}
+	for _, childId := range children[chirpId] {
+		node.Replies = append(node.Replies, buildThread(childId, chirpsById, children, replyCounts))
+	}
+	return node
+}
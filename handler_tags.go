@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/nacen-dev/chirpy/internal/database"
+)
+
+type TrendingTag struct {
+	Name       string `json:"name"`
+	ChirpCount int64  `json:"chirp_count"`
+}
+
+func (cfg *apiConfig) handleGetTagChirps(res http.ResponseWriter, req *http.Request) {
+	tag := strings.ToLower(strings.TrimPrefix(req.PathValue("tag"), "#"))
+	if tag == "" {
+		respondWithError(res, http.StatusBadRequest, "Tag is missing", nil)
+		return
+	}
+
+	page, err := parsePageParams(req)
+	if err != nil {
+		respondWithError(res, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	chirps, err := cfg.db.GetTagChirps(req.Context(), database.GetTagChirpsParams{
+		TagName:         tag,
+		HasCursor:       page.HasCursor,
+		CursorCreatedAt: page.Cursor.CreatedAt,
+		CursorID:        page.Cursor.ID,
+		PageLimit:       page.Limit + 1,
+	})
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to get chirps for the tag", err)
+		return
+	}
+
+	if len(chirps) > int(page.Limit) {
+		chirps = chirps[:page.Limit]
+		last := chirps[len(chirps)-1]
+		setNextPageLink(res, req, pageCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+
+	response, err := cfg.chirpResponses(req.Context(), chirps, cfg.optionalUserID(req))
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to get chirps for the tag", err)
+		return
+	}
+	respondWithJSON(res, http.StatusOK, response)
+}
+
+func (cfg *apiConfig) handleGetTrendingTags(res http.ResponseWriter, req *http.Request) {
+	const (
+		defaultTrendingWindowHours = 24
+		maxTrendingWindowHours     = 24 * 7
+		defaultTrendingTags        = 10
+	)
+
+	windowHours := defaultTrendingWindowHours
+	if hours := req.URL.Query().Get("hours"); hours != "" {
+		parsedHours, err := strconv.Atoi(hours)
+		if err != nil || parsedHours < 1 || parsedHours > maxTrendingWindowHours {
+			respondWithError(res, http.StatusBadRequest, "hours must be between 1 and 168", err)
+			return
+		}
+		windowHours = parsedHours
+	}
+
+	limit := defaultTrendingTags
+	if tagLimit := req.URL.Query().Get("limit"); tagLimit != "" {
+		parsedLimit, err := strconv.Atoi(tagLimit)
+		if err != nil || parsedLimit < 1 {
+			respondWithError(res, http.StatusBadRequest, "limit must be a positive integer", err)
+			return
+		}
+		limit = min(parsedLimit, maxPageLimit)
+	}
+
+	trendingTags, err := cfg.db.GetTrendingTags(req.Context(), database.GetTrendingTagsParams{
+		WindowHours: int32(windowHours),
+		TagLimit:    int32(limit),
+	})
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to get trending tags", err)
+		return
+	}
+
+	response := []TrendingTag{}
+	for _, trendingTag := range trendingTags {
+		response = append(response, TrendingTag{
+			Name:       trendingTag.Name,
+			ChirpCount: trendingTag.ChirpCount,
+		})
+	}
+	respondWithJSON(res, http.StatusOK, response)
+}
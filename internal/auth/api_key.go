@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// GetAPIKey returns the key from an "Authorization: ApiKey <key>" header.
+func GetAPIKey(headers http.Header) (string, error) {
+	splittedValue := strings.Fields(headers.Get("Authorization"))
+
+	if len(splittedValue) == 0 {
+		return "", fmt.Errorf("api key is missing")
+	}
+
+	if splittedValue[0] != "ApiKey" || len(splittedValue) != 2 {
+		return "", fmt.Errorf("malformed api key")
+	}
+
+	return splittedValue[1], nil
+}
+
+// APIKeyMatches compares an API key with the expected one in constant time.
+// An empty expected key matches nothing.
+func APIKeyMatches(apiKey string, expected string) bool {
+	if expected == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(apiKey), []byte(expected)) == 1
+}
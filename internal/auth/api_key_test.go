@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestGetAPIKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		want    string
+		wantErr bool
+	}{
+		{name: "Valid key", header: "ApiKey abc123", want: "abc123"},
+		{name: "Missing header", header: "", wantErr: true},
+		{name: "Wrong scheme", header: "Bearer abc123", wantErr: true},
+		{name: "Extra fields", header: "ApiKey abc 123", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := http.Header{}
+			if tt.header != "" {
+				headers.Set("Authorization", tt.header)
+			}
+			got, err := GetAPIKey(headers)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetAPIKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("GetAPIKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPIKeyMatches(t *testing.T) {
+	if !APIKeyMatches("secret", "secret") {
+		t.Error("APIKeyMatches() = false for the expected key")
+	}
+	if APIKeyMatches("secreT", "secret") {
+		t.Error("APIKeyMatches() = true for a different key")
+	}
+	if APIKeyMatches("", "") {
+		t.Error("APIKeyMatches() = true with no expected key configured")
+	}
+}
@@ -2,12 +2,12 @@ package auth
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"net/http"
 	"strings"
-	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
@@ -16,35 +16,16 @@ import (
 type TokenType string
 
 const (
-	TokenTypeAccess TokenType = "chirpy-access"
+	TokenTypeAccess       TokenType = "chirpy-access"
+	TokenTypeMFAChallenge TokenType = "chirpy-mfa"
 )
 
 var ErrNoAuthHeaderIncluded = errors.New("no auth header included in request")
 var DefaultExpirationInHours = 1
 
-func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
-		Issuer:    string(TokenTypeAccess),
-		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
-		Subject:   userID.String(),
-	})
-	return token.SignedString([]byte(tokenSecret))
-}
-
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	claimsStruct := jwt.RegisteredClaims{}
-
-	token, err := jwt.ParseWithClaims(tokenString, &claimsStruct, func(token *jwt.Token) (any, error) {
-		if token.Method.Alg() != jwt.SigningMethodHS256.Name {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(tokenSecret), nil
-	})
-	if err != nil {
-		return uuid.Nil, err
-	}
-
+// userIDFromToken checks that a verified token is of the expected type and
+// returns its subject.
+func userIDFromToken(token *jwt.Token, tokenType TokenType) (uuid.UUID, error) {
 	userId, err := token.Claims.GetSubject()
 	if err != nil {
 		return uuid.Nil, err
@@ -54,7 +35,7 @@ func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
 	if err != nil {
 		return uuid.Nil, err
 	}
-	if issuer != string(TokenTypeAccess) {
+	if issuer != string(tokenType) {
 		return uuid.Nil, errors.New("invalid issuer")
 	}
 
@@ -82,3 +63,13 @@ func MakeRefreshToken() (string, error) {
 	rand.Read(refreshToken)
 	return hex.EncodeToString(refreshToken), nil
 }
+
+// HashToken returns the digest refresh tokens, personal access tokens,
+// password reset tokens and recovery codes are stored and looked up by. All
+// of them are long random strings rather than something a person chose, so a
+// plain SHA-256 is enough to make a leaked digest useless without slowing
+// down every request.
+func HashToken(token string) string {
+	digest := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(digest[:])
+}
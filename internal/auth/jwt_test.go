@@ -11,8 +11,9 @@ func TestCheckPasswordHash(t *testing.T) {
 	// First, we need to create some hashed passwords for testing
 	password1 := "correctPassword123!"
 	password2 := "anotherPassword456!"
-	hash1, _ := HashPassword(password1)
-	hash2, _ := HashPassword(password2)
+	hasher := &Argon2idHasher{Params: testArgon2idParams}
+	hash1, _ := hasher.Hash(password1)
+	hash2, _ := hasher.Hash(password2)
 
 	tests := []struct {
 		name     string
@@ -64,7 +65,9 @@ func TestCheckPasswordHash(t *testing.T) {
 
 func TestValidateJWT(t *testing.T) {
 	userID := uuid.New()
-	validToken, _ := MakeJWT(userID, "secret", time.Hour)
+	keyRing := NewKeyRing()
+	keyRing.SetHMACSecret("secret")
+	validToken, _ := keyRing.MakeJWT(userID, 0, time.Hour)
 
 	tests := []struct {
 		name        string
@@ -98,14 +101,49 @@ func TestValidateJWT(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotUserID, err := ValidateJWT(tt.tokenString, tt.tokenSecret)
+			keyRing := NewKeyRing()
+			keyRing.SetHMACSecret(tt.tokenSecret)
+			accessToken, err := keyRing.ValidateJWT(tt.tokenString)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("ValidateJWT() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-			if gotUserID != tt.wantUserID {
-				t.Errorf("ValidateJWT() gotUserID = %v, want %v", gotUserID, tt.wantUserID)
+			if accessToken.UserID != tt.wantUserID {
+				t.Errorf("ValidateJWT() gotUserID = %v, want %v", accessToken.UserID, tt.wantUserID)
 			}
 		})
 	}
 }
+
+func TestHashToken(t *testing.T) {
+	token, _ := MakeRefreshToken()
+	otherToken, _ := MakeRefreshToken()
+
+	hash := HashToken(token)
+	if hash == token {
+		t.Errorf("HashToken() returned the token unchanged")
+	}
+	if HashToken(token) != hash {
+		t.Errorf("HashToken() is not deterministic")
+	}
+	if HashToken(otherToken) == hash {
+		t.Errorf("HashToken() gave two tokens the same hash")
+	}
+}
+
+func TestMakePersonalAccessToken(t *testing.T) {
+	token, err := MakePersonalAccessToken()
+	if err != nil {
+		t.Fatalf("MakePersonalAccessToken() error = %v", err)
+	}
+	if !IsPersonalAccessToken(token) || len(token) != len(PersonalAccessTokenPrefix)+64 {
+		t.Errorf("MakePersonalAccessToken() = %q, want %s followed by 64 hex digits", token, PersonalAccessTokenPrefix)
+	}
+
+	keyRing := NewKeyRing()
+	keyRing.SetHMACSecret("secret")
+	jwt, _ := keyRing.MakeJWT(uuid.New(), 0, time.Hour)
+	if IsPersonalAccessToken(jwt) {
+		t.Errorf("IsPersonalAccessToken() = true for a JWT")
+	}
+}
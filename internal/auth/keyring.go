@@ -0,0 +1,334 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const minRSAKeyBits = 2048
+
+// hmacKeyID is the kid of tokens signed with the shared HS256 secret.
+// Tokens issued before a key ring was configured have no kid at all.
+const hmacKeyID = "hs256"
+
+var ErrUnknownKey = errors.New("token was signed with an unknown key")
+
+// KeyRing signs access tokens with one key and verifies them with any key it
+// holds, so keys can be rotated without logging everyone out: a new key is
+// added and made the signing key, and the old one stays in the ring until
+// the tokens it signed have expired.
+//
+// RSA keys sign with RS256 and Ed25519 keys with EdDSA. A shared HS256
+// secret can be added as a fallback; it is used for signing only when the
+// ring has no private key.
+type KeyRing struct {
+	keys          map[string]verificationKey
+	privateKeys   map[string]crypto.PrivateKey
+	signingKeyID  string
+	signingMethod jwt.SigningMethod
+	signingKey    crypto.PrivateKey
+	hmacSecret    []byte
+}
+
+type verificationKey struct {
+	method jwt.SigningMethod
+	key    crypto.PublicKey
+}
+
+func NewKeyRing() *KeyRing {
+	return &KeyRing{
+		keys:        map[string]verificationKey{},
+		privateKeys: map[string]crypto.PrivateKey{},
+	}
+}
+
+// LoadKeyRing loads every *.pem file in dir into a new key ring, using the
+// file name without its extension as the key ID. signingKeyID picks the key
+// that signs new tokens and has to name a private key.
+func LoadKeyRing(dir string, signingKeyID string) (*KeyRing, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	keyRing := NewKeyRing()
+	for _, path := range paths {
+		pemBytes, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		keyID := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		err = keyRing.AddPEM(keyID, pemBytes)
+		if err != nil {
+			return nil, fmt.Errorf("loading %s: %w", path, err)
+		}
+	}
+
+	err = keyRing.SetSigningKey(signingKeyID)
+	if err != nil {
+		return nil, err
+	}
+	return keyRing, nil
+}
+
+// AddPEM adds an RSA or Ed25519 key in PEM form. Public keys can only verify
+// tokens; private keys can also be made the signing key.
+func (k *KeyRing) AddPEM(keyID string, pemBytes []byte) error {
+	if keyID == "" || keyID == hmacKeyID {
+		return fmt.Errorf("invalid key id %q", keyID)
+	}
+	if _, ok := k.keys[keyID]; ok {
+		return fmt.Errorf("duplicate key id %q", keyID)
+	}
+
+	key, err := parsePEMKey(pemBytes)
+	if err != nil {
+		return err
+	}
+
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		k.keys[keyID] = verificationKey{method: jwt.SigningMethodRS256, key: &key.PublicKey}
+		k.privateKeys[keyID] = key
+	case ed25519.PrivateKey:
+		k.keys[keyID] = verificationKey{method: jwt.SigningMethodEdDSA, key: key.Public()}
+		k.privateKeys[keyID] = key
+	case *rsa.PublicKey:
+		k.keys[keyID] = verificationKey{method: jwt.SigningMethodRS256, key: key}
+	case ed25519.PublicKey:
+		k.keys[keyID] = verificationKey{method: jwt.SigningMethodEdDSA, key: key}
+	default:
+		return fmt.Errorf("unsupported key type %T", key)
+	}
+	return nil
+}
+
+// SetSigningKey makes the private key with the given ID sign new tokens.
+func (k *KeyRing) SetSigningKey(keyID string) error {
+	key, ok := k.privateKeys[keyID]
+	if !ok {
+		return fmt.Errorf("no private key with id %q", keyID)
+	}
+	k.signingKeyID = keyID
+	k.signingMethod = k.keys[keyID].method
+	k.signingKey = key
+	return nil
+}
+
+// SetHMACSecret adds the shared HS256 secret. It keeps tokens signed before
+// the switch to asymmetric keys valid and signs new tokens if the ring has no
+// signing key.
+func (k *KeyRing) SetHMACSecret(secret string) {
+	k.hmacSecret = []byte(secret)
+}
+
+// AccessToken is what a valid access token says about its holder.
+type AccessToken struct {
+	UserID uuid.UUID
+	// TokenVersion is the user's token version when the token was issued.
+	// Tokens without one predate versioning and count as version 0.
+	TokenVersion int32
+}
+
+type accessClaims struct {
+	jwt.RegisteredClaims
+	TokenVersion int32 `json:"ver"`
+}
+
+// MakeJWT issues an access token for the user. The token's kid header names
+// the key that signed it and its ver claim carries tokenVersion, so bumping
+// the user's version invalidates every token issued before.
+func (k *KeyRing) MakeJWT(userID uuid.UUID, tokenVersion int32, expiresIn time.Duration) (string, error) {
+	return k.sign(accessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    string(TokenTypeAccess),
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+			Subject:   userID.String(),
+		},
+		TokenVersion: tokenVersion,
+	})
+}
+
+// ValidateJWT checks an access token and returns what it says about its
+// holder. Whether the token version is still current is up to the caller.
+func (k *KeyRing) ValidateJWT(tokenString string) (AccessToken, error) {
+	claimsStruct := accessClaims{}
+	token, err := k.parse(tokenString, &claimsStruct)
+	if err != nil {
+		return AccessToken{}, err
+	}
+
+	userID, err := userIDFromToken(token, TokenTypeAccess)
+	if err != nil {
+		return AccessToken{}, err
+	}
+	return AccessToken{UserID: userID, TokenVersion: claimsStruct.TokenVersion}, nil
+}
+
+// MakeMFAChallengeJWT issues the token a user who passed the password check
+// exchanges, together with a second factor, for an access token. It has its
+// own issuer, so it can't be used as an access token itself.
+func (k *KeyRing) MakeMFAChallengeJWT(userID uuid.UUID, expiresIn time.Duration) (string, error) {
+	return k.sign(jwt.RegisteredClaims{
+		Issuer:    string(TokenTypeMFAChallenge),
+		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+		Subject:   userID.String(),
+	})
+}
+
+// ValidateMFAChallengeJWT checks an MFA challenge token and returns the user
+// it was issued to.
+func (k *KeyRing) ValidateMFAChallengeJWT(tokenString string) (uuid.UUID, error) {
+	token, err := k.parse(tokenString, &jwt.RegisteredClaims{})
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return userIDFromToken(token, TokenTypeMFAChallenge)
+}
+
+func (k *KeyRing) sign(claims jwt.Claims) (string, error) {
+	if k.signingKey != nil {
+		token := jwt.NewWithClaims(k.signingMethod, claims)
+		token.Header["kid"] = k.signingKeyID
+		return token.SignedString(k.signingKey)
+	}
+	if len(k.hmacSecret) > 0 {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		token.Header["kid"] = hmacKeyID
+		return token.SignedString(k.hmacSecret)
+	}
+	return "", errors.New("key ring has no signing key")
+}
+
+// parse verifies a token against the key named by its kid header. The
+// token's alg has to be the one the key is used with, so a public key can
+// never be used as an HMAC secret.
+func (k *KeyRing) parse(tokenString string, claims jwt.Claims) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
+		keyID, _ := token.Header["kid"].(string)
+
+		if keyID == "" || keyID == hmacKeyID {
+			if len(k.hmacSecret) == 0 {
+				return nil, ErrUnknownKey
+			}
+			if token.Method.Alg() != jwt.SigningMethodHS256.Name {
+				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+			}
+			return k.hmacSecret, nil
+		}
+
+		key, ok := k.keys[keyID]
+		if !ok {
+			return nil, ErrUnknownKey
+		}
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return key.key, nil
+	})
+}
+
+// JWK is a public key in JSON Web Key form (RFC 7517).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	// RSA keys
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519 keys
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys other services need to verify access tokens,
+// sorted by key ID. The HMAC secret is never part of it.
+func (k *KeyRing) JWKS() JWKS {
+	keyIDs := make([]string, 0, len(k.keys))
+	for keyID := range k.keys {
+		keyIDs = append(keyIDs, keyID)
+	}
+	sort.Strings(keyIDs)
+
+	jwks := JWKS{Keys: []JWK{}}
+	for _, keyID := range keyIDs {
+		key := k.keys[keyID]
+		jwk := JWK{
+			KeyID:     keyID,
+			Use:       "sig",
+			Algorithm: key.method.Alg(),
+		}
+		switch publicKey := key.key.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(publicKey)
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	return jwks
+}
+
+func parsePEMKey(pemBytes []byte) (any, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var key any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		if key.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("RSA keys must be at least %d bits", minRSAKeyBits)
+		}
+	case *rsa.PublicKey:
+		if key.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("RSA keys must be at least %d bits", minRSAKeyBits)
+		}
+	}
+	return key, nil
+}
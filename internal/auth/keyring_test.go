@@ -0,0 +1,253 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func rsaPrivateKeyPEM(t *testing.T) []byte {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+}
+
+func ed25519PrivateKeyPEM(t *testing.T) []byte {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func publicKeyPEM(t *testing.T, privateKeyPEM []byte) []byte {
+	t.Helper()
+	key, err := parsePEMKey(privateKeyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var publicKey any
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		publicKey = &key.PublicKey
+	case ed25519.PrivateKey:
+		publicKey = key.Public()
+	}
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func TestKeyRingRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		pem     []byte
+		wantAlg string
+	}{
+		{name: "RSA", pem: rsaPrivateKeyPEM(t), wantAlg: "RS256"},
+		{name: "Ed25519", pem: ed25519PrivateKeyPEM(t), wantAlg: "EdDSA"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyRing := NewKeyRing()
+			if err := keyRing.AddPEM("key-1", tt.pem); err != nil {
+				t.Fatalf("AddPEM() error = %v", err)
+			}
+			if err := keyRing.SetSigningKey("key-1"); err != nil {
+				t.Fatalf("SetSigningKey() error = %v", err)
+			}
+
+			userID := uuid.New()
+			tokenString, err := keyRing.MakeJWT(userID, 0, time.Hour)
+			if err != nil {
+				t.Fatalf("MakeJWT() error = %v", err)
+			}
+
+			token, _, err := jwt.NewParser().ParseUnverified(tokenString, &jwt.RegisteredClaims{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if token.Header["kid"] != "key-1" || token.Method.Alg() != tt.wantAlg {
+				t.Errorf("MakeJWT() header = %v, want kid key-1 and alg %s", token.Header, tt.wantAlg)
+			}
+
+			accessToken, err := keyRing.ValidateJWT(tokenString)
+			if err != nil {
+				t.Fatalf("ValidateJWT() error = %v", err)
+			}
+			if accessToken.UserID != userID {
+				t.Errorf("ValidateJWT() UserID = %v, want %v", accessToken.UserID, userID)
+			}
+		})
+	}
+}
+
+func TestKeyRingRotation(t *testing.T) {
+	oldKey := rsaPrivateKeyPEM(t)
+	oldKeyRing := NewKeyRing()
+	oldKeyRing.AddPEM("old", oldKey)
+	oldKeyRing.SetSigningKey("old")
+	userID := uuid.New()
+	oldToken, _ := oldKeyRing.MakeJWT(userID, 0, time.Hour)
+
+	// After rotation only the public half of the old key is left.
+	keyRing := NewKeyRing()
+	if err := keyRing.AddPEM("old", publicKeyPEM(t, oldKey)); err != nil {
+		t.Fatalf("AddPEM() error = %v", err)
+	}
+	if err := keyRing.SetSigningKey("old"); err == nil {
+		t.Errorf("SetSigningKey() accepted a public key")
+	}
+	keyRing.AddPEM("new", ed25519PrivateKeyPEM(t))
+	keyRing.SetSigningKey("new")
+
+	if accessToken, err := keyRing.ValidateJWT(oldToken); err != nil || accessToken.UserID != userID {
+		t.Errorf("ValidateJWT(old token) = %v, %v, want %v", accessToken.UserID, err, userID)
+	}
+
+	newToken, _ := keyRing.MakeJWT(userID, 0, time.Hour)
+	if _, err := oldKeyRing.ValidateJWT(newToken); err == nil {
+		t.Errorf("ValidateJWT() accepted a token signed with a key it doesn't have")
+	}
+}
+
+func TestKeyRingHMACFallback(t *testing.T) {
+	userID := uuid.New()
+	// Tokens from before the key ring have no kid or ver.
+	legacyToken, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Issuer:    string(TokenTypeAccess),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		Subject:   userID.String(),
+	}).SignedString([]byte("secret"))
+
+	keyRing := NewKeyRing()
+	keyRing.AddPEM("key-1", rsaPrivateKeyPEM(t))
+	keyRing.SetSigningKey("key-1")
+
+	if _, err := keyRing.ValidateJWT(legacyToken); err == nil {
+		t.Errorf("ValidateJWT() accepted an HS256 token without a secret")
+	}
+
+	keyRing.SetHMACSecret("secret")
+	accessToken, err := keyRing.ValidateJWT(legacyToken)
+	if err != nil || accessToken.UserID != userID || accessToken.TokenVersion != 0 {
+		t.Errorf("ValidateJWT(legacy token) = %+v, %v, want %v at version 0", accessToken, err, userID)
+	}
+
+	hmacOnly := NewKeyRing()
+	hmacOnly.SetHMACSecret("secret")
+	token, err := hmacOnly.MakeJWT(userID, 0, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT() error = %v", err)
+	}
+	if accessToken, err := keyRing.ValidateJWT(token); err != nil || accessToken.UserID != userID {
+		t.Errorf("ValidateJWT() = %v, %v, want %v", accessToken.UserID, err, userID)
+	}
+}
+
+func TestKeyRingTokenVersion(t *testing.T) {
+	keyRing := NewKeyRing()
+	keyRing.AddPEM("key-1", ed25519PrivateKeyPEM(t))
+	keyRing.SetSigningKey("key-1")
+
+	userID := uuid.New()
+	tokenString, _ := keyRing.MakeJWT(userID, 7, time.Hour)
+	accessToken, err := keyRing.ValidateJWT(tokenString)
+	if err != nil {
+		t.Fatalf("ValidateJWT() error = %v", err)
+	}
+	if accessToken.TokenVersion != 7 {
+		t.Errorf("ValidateJWT() TokenVersion = %d, want 7", accessToken.TokenVersion)
+	}
+}
+
+func TestKeyRingMFAChallenge(t *testing.T) {
+	keyRing := NewKeyRing()
+	keyRing.AddPEM("key-1", ed25519PrivateKeyPEM(t))
+	keyRing.SetSigningKey("key-1")
+
+	userID := uuid.New()
+	challenge, _ := keyRing.MakeMFAChallengeJWT(userID, time.Minute)
+	if gotUserID, err := keyRing.ValidateMFAChallengeJWT(challenge); err != nil || gotUserID != userID {
+		t.Errorf("ValidateMFAChallengeJWT() = %v, %v, want %v", gotUserID, err, userID)
+	}
+	if _, err := keyRing.ValidateJWT(challenge); err == nil {
+		t.Errorf("ValidateJWT() accepted an MFA challenge token")
+	}
+
+	accessToken, _ := keyRing.MakeJWT(userID, 0, time.Minute)
+	if _, err := keyRing.ValidateMFAChallengeJWT(accessToken); err == nil {
+		t.Errorf("ValidateMFAChallengeJWT() accepted an access token")
+	}
+}
+
+func TestKeyRingRejectsAlgorithmConfusion(t *testing.T) {
+	keyPEM := rsaPrivateKeyPEM(t)
+	keyRing := NewKeyRing()
+	keyRing.AddPEM("key-1", keyPEM)
+	keyRing.SetSigningKey("key-1")
+
+	// An attacker who knows the public key signs an HS256 token with it.
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Issuer:    string(TokenTypeAccess),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		Subject:   uuid.New().String(),
+	})
+	token.Header["kid"] = "key-1"
+	forged, err := token.SignedString(publicKeyPEM(t, keyPEM))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := keyRing.ValidateJWT(forged); err == nil {
+		t.Errorf("ValidateJWT() accepted an HS256 token signed with the public key")
+	}
+}
+
+func TestLoadKeyRing(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "2024-01.pem"), publicKeyPEM(t, rsaPrivateKeyPEM(t)), 0o600)
+	os.WriteFile(filepath.Join(dir, "2024-06.pem"), ed25519PrivateKeyPEM(t), 0o600)
+	os.WriteFile(filepath.Join(dir, "README"), []byte("not a key"), 0o600)
+
+	if _, err := LoadKeyRing(dir, "2024-01"); err == nil {
+		t.Errorf("LoadKeyRing() accepted a public key as the signing key")
+	}
+
+	keyRing, err := LoadKeyRing(dir, "2024-06")
+	if err != nil {
+		t.Fatalf("LoadKeyRing() error = %v", err)
+	}
+
+	jwks := keyRing.JWKS()
+	if len(jwks.Keys) != 2 {
+		t.Fatalf("JWKS() has %d keys, want 2", len(jwks.Keys))
+	}
+	rsaKey, edKey := jwks.Keys[0], jwks.Keys[1]
+	if rsaKey.KeyID != "2024-01" || rsaKey.KeyType != "RSA" || rsaKey.Algorithm != "RS256" || rsaKey.N == "" || rsaKey.E != "AQAB" {
+		t.Errorf("JWKS() RSA key = %+v", rsaKey)
+	}
+	if edKey.KeyID != "2024-06" || edKey.KeyType != "OKP" || edKey.Curve != "Ed25519" || edKey.Algorithm != "EdDSA" || edKey.X == "" {
+		t.Errorf("JWKS() Ed25519 key = %+v", edKey)
+	}
+}
@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testArgon2idParams keep the tests fast; they are far too weak for real use.
+var testArgon2idParams = Argon2idParams{
+	Memory:      64,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+func TestArgon2idHasher(t *testing.T) {
+	hasher, err := NewArgon2idHasher(testArgon2idParams)
+	if err != nil {
+		t.Fatalf("NewArgon2idHasher() error = %v", err)
+	}
+
+	hash, err := hasher.Hash("correctPassword123!")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Errorf("Hash() = %q, want a PHC argon2id string", hash)
+	}
+
+	if err := CheckPasswordHash("correctPassword123!", hash); err != nil {
+		t.Errorf("CheckPasswordHash() error = %v for the right password", err)
+	}
+	if err := CheckPasswordHash("wrongPassword", hash); !errors.Is(err, ErrPasswordMismatch) {
+		t.Errorf("CheckPasswordHash() error = %v for a wrong password, want %v", err, ErrPasswordMismatch)
+	}
+
+	other, _ := hasher.Hash("correctPassword123!")
+	if other == hash {
+		t.Error("Hash() returned the same hash twice, the salt isn't random")
+	}
+}
+
+func TestBcryptHasher(t *testing.T) {
+	hasher, err := NewBcryptHasher(bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("NewBcryptHasher() error = %v", err)
+	}
+
+	hash, err := hasher.Hash("correctPassword123!")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+	if err := CheckPasswordHash("correctPassword123!", hash); err != nil {
+		t.Errorf("CheckPasswordHash() error = %v for the right password", err)
+	}
+	if err := CheckPasswordHash("wrongPassword", hash); !errors.Is(err, ErrPasswordMismatch) {
+		t.Errorf("CheckPasswordHash() error = %v for a wrong password, want %v", err, ErrPasswordMismatch)
+	}
+}
+
+func TestNewHasherRejectsWeakOptions(t *testing.T) {
+	if _, err := NewBcryptHasher(bcrypt.MaxCost + 1); !errors.Is(err, ErrInvalidHashOptions) {
+		t.Errorf("NewBcryptHasher() error = %v, want %v", err, ErrInvalidHashOptions)
+	}
+
+	params := testArgon2idParams
+	params.Iterations = 0
+	if _, err := NewArgon2idHasher(params); !errors.Is(err, ErrInvalidHashOptions) {
+		t.Errorf("NewArgon2idHasher() error = %v, want %v", err, ErrInvalidHashOptions)
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	bcryptHash, _ := (&BcryptHasher{Cost: bcrypt.MinCost}).Hash("password")
+	argon2idHash, _ := (&Argon2idHasher{Params: testArgon2idParams}).Hash("password")
+
+	strongerParams := testArgon2idParams
+	strongerParams.Iterations = 2
+
+	tests := []struct {
+		name   string
+		hasher PasswordHasher
+		hash   string
+		want   bool
+	}{
+		{
+			name:   "Argon2id hash with current parameters",
+			hasher: &Argon2idHasher{Params: testArgon2idParams},
+			hash:   argon2idHash,
+			want:   false,
+		},
+		{
+			name:   "Argon2id hash with outdated parameters",
+			hasher: &Argon2idHasher{Params: strongerParams},
+			hash:   argon2idHash,
+			want:   true,
+		},
+		{
+			name:   "Bcrypt hash when hashing with argon2id",
+			hasher: &Argon2idHasher{Params: testArgon2idParams},
+			hash:   bcryptHash,
+			want:   true,
+		},
+		{
+			name:   "Bcrypt hash with current cost",
+			hasher: &BcryptHasher{Cost: bcrypt.MinCost},
+			hash:   bcryptHash,
+			want:   false,
+		},
+		{
+			name:   "Bcrypt hash with outdated cost",
+			hasher: &BcryptHasher{Cost: bcrypt.MinCost + 1},
+			hash:   bcryptHash,
+			want:   true,
+		},
+		{
+			name:   "Argon2id hash when hashing with bcrypt",
+			hasher: &BcryptHasher{Cost: bcrypt.MinCost},
+			hash:   argon2idHash,
+			want:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.hasher.NeedsRehash(tt.hash); got != tt.want {
+				t.Errorf("NeedsRehash() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckPasswordHashMalformed(t *testing.T) {
+	hashes := []string{
+		"",
+		"unset",
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdA",
+		"$argon2id$v=16$m=64,t=1,p=1$c2FsdHNhbHQ$a2V5a2V5a2V5a2V5",
+		"$argon2id$v=19$m=x,t=1,p=1$c2FsdHNhbHQ$a2V5a2V5a2V5a2V5",
+	}
+	for _, hash := range hashes {
+		err := CheckPasswordHash("password", hash)
+		if err == nil {
+			t.Errorf("CheckPasswordHash(%q) error = nil, want an error", hash)
+		}
+	}
+}
@@ -1,22 +1,91 @@
 package auth
 
 import (
-	"fmt"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 )
 
-func GetPolkaAPIKey(headers http.Header) (string, error) {
-	polkaKey := headers.Get("Authorization")
-	splittedValue := strings.Fields(polkaKey)
+// Polka signs each webhook with HMAC-SHA256 over "<timestamp>.<body>" and
+// sends the result as:
+//
+//	X-Polka-Timestamp: 1700000000
+//	X-Polka-Signature: v1=<hex digest>
+//
+// The signature header can hold several comma-separated v1 entries while
+// Polka rotates its secret.
+const (
+	PolkaTimestampHeader = "X-Polka-Timestamp"
+	PolkaSignatureHeader = "X-Polka-Signature"
+	polkaSignatureScheme = "v1"
+)
+
+var (
+	ErrPolkaSignatureMissing = errors.New("polka signature or timestamp is missing")
+	ErrPolkaTimestampInvalid = errors.New("polka timestamp is invalid")
+	ErrPolkaTimestampExpired = errors.New("polka timestamp is outside the tolerance window")
+	ErrPolkaSignatureInvalid = errors.New("polka signature does not match")
+)
+
+// SignPolkaPayload returns the hex signature Polka sends for body at
+// timestamp.
+func SignPolkaPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
 
-	if len(splittedValue) == 0 {
-		return "", fmt.Errorf("api key is missing")
+// VerifyPolkaSignature checks that body was signed with one of secrets, which
+// are all accepted so ours can be rotated too, and that the signature's
+// timestamp is within tolerance of now in either direction. The timestamp is
+// part of what is signed, so a captured request can't be replayed once it
+// is outside the window.
+func VerifyPolkaSignature(headers http.Header, body []byte, secrets []string, now time.Time, tolerance time.Duration) error {
+	timestampHeader := headers.Get(PolkaTimestampHeader)
+	signatureHeader := headers.Get(PolkaSignatureHeader)
+	if timestampHeader == "" || signatureHeader == "" {
+		return ErrPolkaSignatureMissing
 	}
 
-	if splittedValue[0] != "ApiKey" || len(splittedValue) != 2 {
-		return "", fmt.Errorf("malformed api key")
+	timestamp, err := strconv.ParseInt(timestampHeader, 10, 64)
+	if err != nil {
+		return ErrPolkaTimestampInvalid
+	}
+	age := now.Sub(time.Unix(timestamp, 0))
+	if age > tolerance || age < -tolerance {
+		return ErrPolkaTimestampExpired
+	}
+
+	signatures := [][]byte{}
+	for _, entry := range strings.Split(signatureHeader, ",") {
+		scheme, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || scheme != polkaSignatureScheme {
+			continue
+		}
+		signature, err := hex.DecodeString(value)
+		if err != nil {
+			continue
+		}
+		signatures = append(signatures, signature)
 	}
 
-	return splittedValue[1], nil
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		}
+		expected, _ := hex.DecodeString(SignPolkaPayload(secret, timestamp, body))
+		for _, signature := range signatures {
+			if hmac.Equal(signature, expected) {
+				return nil
+			}
+		}
+	}
+	return ErrPolkaSignatureInvalid
 }
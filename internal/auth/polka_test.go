@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerifyPolkaSignature(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"event":"user.upgraded","data":{"user_id":"3311741c-680c-4546-99f3-fc9efac2036c"}}`)
+	secrets := []string{"new-secret", "old-secret"}
+	tolerance := 5 * time.Minute
+
+	polkaHeaders := func(timestamp int64, signature string) http.Header {
+		headers := http.Header{}
+		headers.Set(PolkaTimestampHeader, strconv.FormatInt(timestamp, 10))
+		headers.Set(PolkaSignatureHeader, signature)
+		return headers
+	}
+	sign := func(secret string, timestamp int64) string {
+		return "v1=" + SignPolkaPayload(secret, timestamp, body)
+	}
+
+	tests := []struct {
+		name    string
+		headers http.Header
+		body    []byte
+		wantErr error
+	}{
+		{
+			name:    "Signed with the current secret",
+			headers: polkaHeaders(now.Unix(), sign("new-secret", now.Unix())),
+			body:    body,
+		},
+		{
+			name:    "Signed with a secret being rotated out",
+			headers: polkaHeaders(now.Unix(), sign("old-secret", now.Unix())),
+			body:    body,
+		},
+		{
+			name:    "One of several signatures matches",
+			headers: polkaHeaders(now.Unix(), sign("unknown", now.Unix())+", "+sign("new-secret", now.Unix())),
+			body:    body,
+		},
+		{
+			name:    "Slightly old timestamp",
+			headers: polkaHeaders(now.Add(-4*time.Minute).Unix(), sign("new-secret", now.Add(-4*time.Minute).Unix())),
+			body:    body,
+		},
+		{
+			name:    "Slightly skewed clock ahead of ours",
+			headers: polkaHeaders(now.Add(time.Minute).Unix(), sign("new-secret", now.Add(time.Minute).Unix())),
+			body:    body,
+		},
+		{
+			name:    "Missing headers",
+			headers: http.Header{},
+			body:    body,
+			wantErr: ErrPolkaSignatureMissing,
+		},
+		{
+			name:    "Zero timestamp",
+			headers: polkaHeaders(0, sign("new-secret", now.Unix())),
+			body:    body,
+			wantErr: ErrPolkaTimestampExpired,
+		},
+		{
+			name: "Non-numeric timestamp",
+			headers: http.Header{
+				PolkaTimestampHeader: []string{"yesterday"},
+				PolkaSignatureHeader: []string{sign("new-secret", now.Unix())},
+			},
+			body:    body,
+			wantErr: ErrPolkaTimestampInvalid,
+		},
+		{
+			name:    "Replayed outside the tolerance window",
+			headers: polkaHeaders(now.Add(-10*time.Minute).Unix(), sign("new-secret", now.Add(-10*time.Minute).Unix())),
+			body:    body,
+			wantErr: ErrPolkaTimestampExpired,
+		},
+		{
+			name:    "Timestamp changed without re-signing",
+			headers: polkaHeaders(now.Unix(), sign("new-secret", now.Add(-10*time.Minute).Unix())),
+			body:    body,
+			wantErr: ErrPolkaSignatureInvalid,
+		},
+		{
+			name:    "Tampered body",
+			headers: polkaHeaders(now.Unix(), sign("new-secret", now.Unix())),
+			body:    []byte(`{"event":"user.upgraded","data":{"user_id":"00000000-0000-0000-0000-000000000000"}}`),
+			wantErr: ErrPolkaSignatureInvalid,
+		},
+		{
+			name:    "Unknown secret",
+			headers: polkaHeaders(now.Unix(), sign("guessed", now.Unix())),
+			body:    body,
+			wantErr: ErrPolkaSignatureInvalid,
+		},
+		{
+			name:    "Unknown signature scheme",
+			headers: polkaHeaders(now.Unix(), "v0="+SignPolkaPayload("new-secret", now.Unix(), body)),
+			body:    body,
+			wantErr: ErrPolkaSignatureInvalid,
+		},
+		{
+			name:    "Signature isn't hex",
+			headers: polkaHeaders(now.Unix(), "v1=not-hex"),
+			body:    body,
+			wantErr: ErrPolkaSignatureInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyPolkaSignature(tt.headers, tt.body, secrets, now, tolerance)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("VerifyPolkaSignature() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyPolkaSignatureIgnoresEmptySecrets(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{}`)
+	headers := http.Header{}
+	headers.Set(PolkaTimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	headers.Set(PolkaSignatureHeader, "v1="+SignPolkaPayload("", now.Unix(), body))
+
+	err := VerifyPolkaSignature(headers, body, []string{""}, now, time.Minute)
+	if !errors.Is(err, ErrPolkaSignatureInvalid) {
+		t.Errorf("VerifyPolkaSignature() error = %v, want %v", err, ErrPolkaSignatureInvalid)
+	}
+}
@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The SHA-1 secret from RFC 6238 appendix B.
+var rfc6238Secret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 appendix B, truncated from 8 to 6 digits.
+	tests := []struct {
+		unixTime int64
+		want     string
+	}{
+		{unixTime: 59, want: "287082"},
+		{unixTime: 1111111109, want: "081804"},
+		{unixTime: 1111111111, want: "050471"},
+		{unixTime: 1234567890, want: "005924"},
+		{unixTime: 2000000000, want: "279037"},
+		{unixTime: 20000000000, want: "353130"},
+	}
+
+	for _, tt := range tests {
+		got, err := TOTPCode(rfc6238Secret, TOTPStep(time.Unix(tt.unixTime, 0)))
+		if err != nil {
+			t.Fatalf("TOTPCode() error = %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("TOTPCode() at %d = %s, want %s", tt.unixTime, got, tt.want)
+		}
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := TOTPStep(now)
+	codeAt := func(step int64) string {
+		code, _ := TOTPCode(rfc6238Secret, step)
+		return code
+	}
+
+	tests := []struct {
+		name         string
+		code         string
+		lastUsedStep int64
+		wantStep     int64
+		wantOK       bool
+	}{
+		{name: "Current code", code: codeAt(step), wantStep: step, wantOK: true},
+		{name: "Previous code within skew", code: codeAt(step - 1), wantStep: step - 1, wantOK: true},
+		{name: "Next code within skew", code: codeAt(step + 1), wantStep: step + 1, wantOK: true},
+		{name: "Code outside skew", code: codeAt(step - 2), wantOK: false},
+		{name: "Replayed code", code: codeAt(step), lastUsedStep: step, wantOK: false},
+		{name: "Code older than last used", code: codeAt(step - 1), lastUsedStep: step, wantOK: false},
+		{name: "Wrong code", code: "000000", wantOK: false},
+		{name: "Malformed code", code: "12345", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStep, gotOK := VerifyTOTP(rfc6238Secret, tt.code, now, tt.lastUsedStep)
+			if gotOK != tt.wantOK || (tt.wantOK && gotStep != tt.wantStep) {
+				t.Errorf("VerifyTOTP() = %d, %v, want %d, %v", gotStep, gotOK, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret() error = %v", err)
+	}
+	if _, err := TOTPCode(secret, 1); err != nil {
+		t.Errorf("TOTPCode() can't use the generated secret: %v", err)
+	}
+
+	uri := TOTPURI(secret, "Chirpy", "walt@example.com")
+	if !strings.HasPrefix(uri, "otpauth://totp/Chirpy:walt@example.com?") || !strings.Contains(uri, "secret="+secret) {
+		t.Errorf("TOTPURI() = %s", uri)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCodes() error = %v", err)
+	}
+	if len(codes) != 10 {
+		t.Fatalf("GenerateRecoveryCodes() returned %d codes, want 10", len(codes))
+	}
+
+	code := codes[0]
+	if len(code) != 19 || strings.Count(code, "-") != 3 {
+		t.Errorf("GenerateRecoveryCodes() code = %q, want xxxx-xxxx-xxxx-xxxx", code)
+	}
+	typed := strings.ToUpper(strings.ReplaceAll(code, "-", " "))
+	if NormalizeRecoveryCode(typed) != code {
+		t.Errorf("NormalizeRecoveryCode(%q) = %q, want %q", typed, NormalizeRecoveryCode(typed), code)
+	}
+}
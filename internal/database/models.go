@@ -6,33 +6,188 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
-type Chirp struct {
+type AuditLog struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
-	UpdatedAt time.Time
-	Body      string
+	Event     string
+	UserID    uuid.NullUUID
+	IpAddress sql.NullString
+	Details   json.RawMessage
+}
+
+type Chirp struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Body         string
+	UserID       uuid.UUID
+	SearchVector interface{}
+	InReplyTo    uuid.NullUUID
+	RechirpOf    uuid.NullUUID
+	QuoteOf      uuid.NullUUID
+	QuoteDeleted bool
+}
+
+type ChirpLike struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type ChirpMention struct {
+	ChirpID     uuid.UUID
+	UserID      uuid.UUID
+	StartOffset int32
+	EndOffset   int32
+}
+
+type ChirpRevision struct {
+	ID         uuid.UUID
+	ChirpID    uuid.UUID
+	Body       string
+	CreatedAt  time.Time
+	ReplacedAt time.Time
+}
+
+type ChirpTag struct {
+	ChirpID        uuid.UUID
+	TagID          uuid.UUID
+	ChirpCreatedAt time.Time
+}
+
+type EmailVerificationToken struct {
+	TokenHash string
+	UserID    uuid.UUID
+	Email     string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type LoginThrottle struct {
+	Key               string
+	Failures          int32
+	LastFailureAt     time.Time
+	PreviousFailureAt sql.NullTime
+}
+
+type PasswordResetToken struct {
+	TokenHash string
+	UserID    uuid.UUID
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type PersonalAccessToken struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	Name       string
+	TokenHash  string
+	Scopes     []string
+	CreatedAt  time.Time
+	LastUsedAt sql.NullTime
+	ExpiresAt  sql.NullTime
+	RevokedAt  sql.NullTime
+}
+
+type RecoveryCode struct {
 	UserID    uuid.UUID
+	CodeHash  string
+	CreatedAt time.Time
+	UsedAt    sql.NullTime
 }
 
 type RefreshToken struct {
-	Token     string
+	TokenHash string
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	UserID    uuid.UUID
 	ExpiresAt time.Time
 	RevokedAt sql.NullTime
+	FamilyID  uuid.UUID
+}
+
+type Session struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+	UserAgent  string
+	IpAddress  string
+	RevokedAt  sql.NullTime
+}
+
+type Subscription struct {
+	ID               uuid.UUID
+	UserID           uuid.UUID
+	Plan             string
+	Status           string
+	CurrentPeriodEnd time.Time
+	CanceledAt       sql.NullTime
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+type SubscriptionEvent struct {
+	ID               uuid.UUID
+	SubscriptionID   uuid.UUID
+	Event            string
+	Status           string
+	CurrentPeriodEnd time.Time
+	WebhookEventID   uuid.NullUUID
+	CreatedAt        time.Time
+}
+
+type Tag struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	Name      string
 }
 
 type User struct {
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Email            string
+	HashedPassword   string
+	IsChirpyRed      bool
+	Username         sql.NullString
+	DisplayName      sql.NullString
+	Bio              sql.NullString
+	AvatarUrl        sql.NullString
+	TokenVersion     int32
+	TotpSecret       sql.NullString
+	TotpEnabledAt    sql.NullTime
+	TotpLastUsedStep int64
+	EmailVerifiedAt  sql.NullTime
+	PendingEmail     sql.NullString
+	SuspendedAt      sql.NullTime
+}
+
+type WebhookEvent struct {
 	ID             uuid.UUID
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
-	IsChirpyRed    sql.NullBool
+	Provider       string
+	EventID        string
+	EventType      string
+	Payload        json.RawMessage
+	ReceivedAt     time.Time
+	Status         string
+	Attempts       int32
+	ResponseStatus sql.NullInt32
+	Error          sql.NullString
+	ProcessedAt    sql.NullTime
+	StartedAt      time.Time
 }
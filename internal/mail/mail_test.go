@@ -0,0 +1,80 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormat(t *testing.T) {
+	date := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	data, err := format("Chirpy <noreply@chirpy.test>", Message{
+		To:      "user@example.com",
+		Subject: "Reset your password",
+		Body:    "line one\nline two",
+	}, date)
+	if err != nil {
+		t.Fatalf("format() error = %v", err)
+	}
+
+	want := "From: Chirpy <noreply@chirpy.test>\r\n" +
+		"To: user@example.com\r\n" +
+		"Subject: Reset your password\r\n" +
+		"Date: Fri, 01 Mar 2024 12:00:00 +0000\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" +
+		"line one\r\nline two"
+	if string(data) != want {
+		t.Errorf("format() =\n%q\nwant\n%q", data, want)
+	}
+}
+
+func TestFormatRejectsHeaderInjection(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  Message
+	}{
+		{
+			name: "Line break in recipient",
+			msg:  Message{To: "user@example.com\r\nBcc: victim@example.com", Subject: "Hi"},
+		},
+		{
+			name: "Line break in subject",
+			msg:  Message{To: "user@example.com", Subject: "Hi\nBcc: victim@example.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := format("noreply@chirpy.test", tt.msg, time.Now())
+			if !errors.Is(err, ErrInvalidHeader) {
+				t.Errorf("format() error = %v, want %v", err, ErrInvalidHeader)
+			}
+		})
+	}
+}
+
+func TestLogMailer(t *testing.T) {
+	var buf bytes.Buffer
+	mailer := &LogMailer{W: &buf, From: "noreply@chirpy.test"}
+
+	err := mailer.Send(context.Background(), Message{
+		To:      "user@example.com",
+		Subject: "Reset your password",
+		Body:    "Your token is abc123",
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{"To: user@example.com", "Subject: Reset your password", "Your token is abc123"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Send() wrote %q, missing %q", out, want)
+		}
+	}
+}
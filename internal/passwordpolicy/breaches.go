@@ -0,0 +1,202 @@
+package passwordpolicy
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// hashPrefixLength is how many hex characters of a SHA-1 select a range, as
+// in the Pwned Passwords range API.
+const hashPrefixLength = 5
+
+// HashRanges looks up breached password hashes the k-anonymity way: given
+// the first five hex characters of a SHA-1, it returns the remaining 35 of
+// every breached hash starting with them. Only the prefix ever leaves the
+// checker, so the source could be a remote service as well as a file.
+type HashRanges interface {
+	Range(prefix string) ([]string, error)
+}
+
+// RangeChecker is a BreachChecker over HashRanges.
+type RangeChecker struct {
+	Ranges HashRanges
+}
+
+func (c *RangeChecker) IsBreached(password string) (bool, error) {
+	digest := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(digest[:]))
+
+	suffixes, err := c.Ranges.Range(hash[:hashPrefixLength])
+	if err != nil {
+		return false, err
+	}
+	for _, suffix := range suffixes {
+		if suffix == hash[hashPrefixLength:] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// SortedHashFile looks up breached password hashes in a file sorted by
+// hash, like the Pwned Passwords download ordered by hash: one hex SHA-1 per
+// line, optionally followed by ":<count>", which is ignored. The hashes can
+// be uppercase or lowercase, as long as they are sorted as if they were all
+// uppercase.
+//
+// Each lookup binary searches the file on disk and only reads the lines of
+// one prefix, so the file can be far larger than memory.
+type SortedHashFile struct {
+	r    io.ReaderAt
+	size int64
+}
+
+// OpenSortedHashFile opens a sorted hash file. The file stays open for the
+// life of the SortedHashFile.
+func OpenSortedHashFile(path string) (*SortedHashFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	hashFile := NewSortedHashFile(file, info.Size())
+	// Catch a file in the wrong format now rather than on the first lookup.
+	if info.Size() > 0 {
+		_, line, err := hashFile.lineAt(0)
+		if err == nil {
+			_, err = hashOf(line)
+		}
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	return hashFile, nil
+}
+
+// NewSortedHashFile reads a sorted hash file of size bytes from r.
+func NewSortedHashFile(r io.ReaderAt, size int64) *SortedHashFile {
+	return &SortedHashFile{r: r, size: size}
+}
+
+func (f *SortedHashFile) Range(prefix string) ([]string, error) {
+	prefix = strings.ToUpper(prefix)
+
+	// Find the smallest offset whose next line has a hash of at least
+	// prefix. Lines only get later as the offset grows, so this is a
+	// binary search over byte offsets.
+	low, high := int64(0), f.size
+	for low < high {
+		middle := low + (high-low)/2
+		_, line, err := f.lineAt(middle)
+		if err != nil {
+			return nil, err
+		}
+		hash := ""
+		if line != "" {
+			hash, err = hashOf(line)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if line == "" || hash >= prefix {
+			high = middle
+		} else {
+			low = middle + 1
+		}
+	}
+
+	suffixes := []string{}
+	offset := low
+	for offset < f.size {
+		next, line, err := f.lineAt(offset)
+		if err != nil {
+			return nil, err
+		}
+		if line == "" {
+			break
+		}
+		hash, err := hashOf(line)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(hash, prefix) {
+			break
+		}
+		suffixes = append(suffixes, hash[hashPrefixLength:])
+		offset = next
+	}
+	return suffixes, nil
+}
+
+// lineReadSize is how much is read at a time while looking for the end of a
+// line. Lines of the Pwned Passwords download are under 64 bytes.
+const lineReadSize = 128
+
+// lineAt returns the first non-empty line that starts at or after offset and
+// the offset right after it. The line is empty at the end of the file.
+func (f *SortedHashFile) lineAt(offset int64) (int64, string, error) {
+	// Unless offset is right after a newline, it is in the middle of a line
+	// that belongs to the offsets before it.
+	if offset > 0 {
+		end, _, err := f.readLine(offset - 1)
+		if err != nil {
+			return 0, "", err
+		}
+		offset = end
+	}
+	for offset < f.size {
+		end, line, err := f.readLine(offset)
+		if err != nil {
+			return 0, "", err
+		}
+		line = strings.TrimSpace(line)
+		if line != "" {
+			return end, line, nil
+		}
+		offset = end
+	}
+	return f.size, "", nil
+}
+
+// readLine reads from offset up to and including the next newline.
+func (f *SortedHashFile) readLine(offset int64) (int64, string, error) {
+	var line []byte
+	buf := make([]byte, lineReadSize)
+	for offset < f.size {
+		n, err := f.r.ReadAt(buf, offset)
+		if n == 0 && err != nil {
+			return 0, "", err
+		}
+		if i := bytes.IndexByte(buf[:n], '\n'); i >= 0 {
+			line = append(line, buf[:i]...)
+			return offset + int64(i) + 1, string(line), nil
+		}
+		line = append(line, buf[:n]...)
+		offset += int64(n)
+	}
+	return f.size, string(line), nil
+}
+
+// hashOf returns the uppercase SHA-1 of a line.
+func hashOf(line string) (string, error) {
+	hash, _, _ := strings.Cut(line, ":")
+	hash = strings.ToUpper(hash)
+	if len(hash) != sha1.Size*2 {
+		return "", fmt.Errorf("not a SHA-1 hash: %q", line)
+	}
+	if _, err := hex.DecodeString(hash); err != nil {
+		return "", fmt.Errorf("not a SHA-1 hash: %q", line)
+	}
+	return hash, nil
+}
@@ -0,0 +1,114 @@
+// Package passwordpolicy decides whether a password is good enough to set,
+// reporting every rule it breaks so a client can show them all at once.
+package passwordpolicy
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Rules a password can break.
+const (
+	RuleMinLength = "min_length"
+	RuleMaxLength = "max_length"
+	RuleCommon    = "common_password"
+	RuleBreached  = "breached_password"
+)
+
+const (
+	DefaultMinLength = 8
+	// MaxBytes is bcrypt's input limit. Argon2id has none, but keeping to it
+	// means every stored password can still be hashed if the hashing
+	// algorithm is switched to bcrypt.
+	MaxBytes = 72
+)
+
+// Violation is a rule the password breaks.
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// BreachChecker reports whether a password is known from a data breach.
+type BreachChecker interface {
+	IsBreached(password string) (bool, error)
+}
+
+//go:embed common_passwords.txt
+var commonPasswordsFile []byte
+
+type Policy struct {
+	// MinLength is in characters, not bytes.
+	MinLength int
+	// MaxBytes is in bytes, since that is what hashing limits.
+	MaxBytes        int
+	CommonPasswords map[string]struct{}
+	// Breaches is optional.
+	Breaches BreachChecker
+}
+
+// Default is the policy with the default lengths and the embedded list of
+// common passwords. Breaches is left for the caller to set.
+func Default() *Policy {
+	return &Policy{
+		MinLength:       DefaultMinLength,
+		MaxBytes:        MaxBytes,
+		CommonPasswords: parseCommonPasswords(commonPasswordsFile),
+	}
+}
+
+func parseCommonPasswords(data []byte) map[string]struct{} {
+	passwords := map[string]struct{}{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		passwords[strings.ToLower(line)] = struct{}{}
+	}
+	return passwords
+}
+
+// Validate returns the rules password breaks, which is none if it is
+// acceptable. The error is only for a breach check that couldn't be done.
+func (p *Policy) Validate(password string) ([]Violation, error) {
+	violations := []Violation{}
+
+	if utf8.RuneCountInString(password) < p.MinLength {
+		violations = append(violations, Violation{
+			Rule:    RuleMinLength,
+			Message: fmt.Sprintf("Password must be at least %d characters long", p.MinLength),
+		})
+	}
+	if len(password) > p.MaxBytes {
+		violations = append(violations, Violation{
+			Rule:    RuleMaxLength,
+			Message: fmt.Sprintf("Password must be at most %d bytes long", p.MaxBytes),
+		})
+	}
+	if _, ok := p.CommonPasswords[strings.ToLower(password)]; ok {
+		violations = append(violations, Violation{
+			Rule:    RuleCommon,
+			Message: "Password is too common",
+		})
+	}
+
+	if p.Breaches != nil && password != "" {
+		breached, err := p.Breaches.IsBreached(password)
+		if err != nil {
+			return nil, err
+		}
+		if breached {
+			violations = append(violations, Violation{
+				Rule:    RuleBreached,
+				Message: "Password has appeared in a data breach",
+			})
+		}
+	}
+	return violations, nil
+}
@@ -0,0 +1,179 @@
+package passwordpolicy
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func rules(violations []Violation) []string {
+	names := []string{}
+	for _, violation := range violations {
+		names = append(names, violation.Rule)
+	}
+	return names
+}
+
+func sha1Hex(s string) string {
+	digest := sha1.Sum([]byte(s))
+	return hex.EncodeToString(digest[:])
+}
+
+func TestValidate(t *testing.T) {
+	policy := Default()
+
+	tests := []struct {
+		name     string
+		password string
+		want     []string
+	}{
+		{name: "Acceptable password", password: "correct horse battery staple", want: []string{}},
+		{name: "Empty password", password: "", want: []string{RuleMinLength}},
+		{name: "Too short", password: "x7#kQ2", want: []string{RuleMinLength}},
+		{name: "Short in bytes but long enough in characters", password: "éééééééé", want: []string{}},
+		{name: "Too long", password: strings.Repeat("a1", 37), want: []string{RuleMaxLength}},
+		{name: "Multibyte characters count towards the byte limit", password: strings.Repeat("é", 37), want: []string{RuleMaxLength}},
+		{name: "Common password", password: "password123", want: []string{RuleCommon}},
+		{name: "Common password in another case", password: "PassWord123", want: []string{RuleCommon}},
+		{name: "Common password at the minimum length", password: "iloveyou", want: []string{RuleCommon}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations, err := policy.Validate(tt.password)
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if got := rules(violations); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() rules = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateBreached(t *testing.T) {
+	hashFile := sortedHashFile(
+		strings.ToUpper(sha1Hex("hunter2hunter2"))+":1234",
+		sha1Hex("another breached one"),
+	)
+	policy := Default()
+	policy.Breaches = &RangeChecker{Ranges: hashFile}
+
+	tests := []struct {
+		name     string
+		password string
+		want     []string
+	}{
+		{name: "Breached password", password: "hunter2hunter2", want: []string{RuleBreached}},
+		{name: "Breached password from a lowercase line", password: "another breached one", want: []string{RuleBreached}},
+		{name: "Password not in the file", password: "correct horse battery staple", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations, err := policy.Validate(tt.password)
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if got := rules(violations); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() rules = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+type failingRanges struct{}
+
+func (failingRanges) Range(prefix string) ([]string, error) {
+	return nil, errors.New("unavailable")
+}
+
+func TestValidateBreachCheckError(t *testing.T) {
+	policy := Default()
+	policy.Breaches = &RangeChecker{Ranges: failingRanges{}}
+
+	_, err := policy.Validate("correct horse battery staple")
+	if err == nil {
+		t.Error("Validate() error = nil, want the breach check's error")
+	}
+}
+
+// sortedHashFile builds a SortedHashFile from lines, which it sorts.
+func sortedHashFile(lines ...string) *SortedHashFile {
+	sort.Slice(lines, func(i, j int) bool {
+		return strings.ToUpper(lines[i]) < strings.ToUpper(lines[j])
+	})
+	content := strings.Join(lines, "\r\n") + "\r\n"
+	return NewSortedHashFile(strings.NewReader(content), int64(len(content)))
+}
+
+func TestSortedHashFileRange(t *testing.T) {
+	lines := []string{}
+	want := map[string][]string{}
+	for i := 0; i < 2000; i++ {
+		hash := strings.ToUpper(sha1Hex(fmt.Sprintf("password %d", i)))
+		lines = append(lines, fmt.Sprintf("%s:%d", hash, i))
+		prefix := hash[:hashPrefixLength]
+		want[prefix] = append(want[prefix], hash[hashPrefixLength:])
+	}
+	// Several hashes under one prefix.
+	for i := 0; i < 3; i++ {
+		hash := "ABCDE" + strings.ToUpper(sha1Hex(fmt.Sprint(i)))[hashPrefixLength:]
+		lines = append(lines, hash)
+		want["ABCDE"] = append(want["ABCDE"], hash[hashPrefixLength:])
+	}
+	hashFile := sortedHashFile(lines...)
+
+	for prefix, suffixes := range want {
+		got, err := hashFile.Range(strings.ToLower(prefix))
+		if err != nil {
+			t.Fatalf("Range(%s) error = %v", prefix, err)
+		}
+		sort.Strings(got)
+		sort.Strings(suffixes)
+		if !reflect.DeepEqual(got, suffixes) {
+			t.Errorf("Range(%s) = %v, want %v", prefix, got, suffixes)
+		}
+	}
+
+	for _, prefix := range []string{"00000", "FFFFF"} {
+		if _, ok := want[prefix]; ok {
+			continue
+		}
+		got, err := hashFile.Range(prefix)
+		if err != nil || len(got) != 0 {
+			t.Errorf("Range(%s) = %v, %v, want no hashes", prefix, got, err)
+		}
+	}
+}
+
+func TestSortedHashFileRejectsMalformedLines(t *testing.T) {
+	inputs := []string{
+		"not a hash\n",
+		strings.Repeat("Z", 40) + "\n",
+		sha1Hex("x")[:39] + ":3\n",
+	}
+	for _, input := range inputs {
+		path := filepath.Join(t.TempDir(), "hashes.txt")
+		if err := os.WriteFile(path, []byte(input), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		_, err := OpenSortedHashFile(path)
+		if err == nil {
+			t.Errorf("OpenSortedHashFile(%q) error = nil, want an error", input)
+		}
+
+		hashFile := NewSortedHashFile(strings.NewReader(input), int64(len(input)))
+		_, err = hashFile.Range(sha1Hex("x")[:hashPrefixLength])
+		if err == nil {
+			t.Errorf("Range() over %q error = nil, want an error", input)
+		}
+	}
+}
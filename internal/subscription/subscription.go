@@ -0,0 +1,67 @@
+// Package subscription describes how a Chirpy Red subscription moves between
+// statuses. The queries in internal/database make the changes; this package
+// decides which statuses each event applies to, what it leaves behind and
+// whether the user keeps Chirpy Red.
+package subscription
+
+const (
+	StatusActive   = "active"
+	StatusPastDue  = "past_due"
+	StatusCanceled = "canceled"
+	StatusExpired  = "expired"
+)
+
+// Events are named after the Polka webhooks that cause them, except for
+// EventExpired, which the expiry job records when a paid period runs out.
+const (
+	EventUpgraded      = "user.upgraded"
+	EventRenewed       = "subscription.renewed"
+	EventPaymentFailed = "payment.failed"
+	EventDowngraded    = "user.downgraded"
+	EventExpired       = "subscription.expired"
+)
+
+// Transition is the change an event makes to a subscription.
+type Transition struct {
+	// From holds the statuses the event applies to. Subscriptions in any
+	// other status are left alone.
+	From []string
+	To   string
+}
+
+var transitions = map[string]Transition{
+	EventUpgraded: {
+		From: []string{StatusActive, StatusPastDue, StatusCanceled, StatusExpired},
+		To:   StatusActive,
+	},
+	EventRenewed: {
+		From: []string{StatusActive, StatusPastDue, StatusCanceled, StatusExpired},
+		To:   StatusActive,
+	},
+	// Only an active subscription can fall behind on payments.
+	EventPaymentFailed: {
+		From: []string{StatusActive},
+		To:   StatusPastDue,
+	},
+	EventDowngraded: {
+		From: []string{StatusActive, StatusPastDue},
+		To:   StatusCanceled,
+	},
+	EventExpired: {
+		From: []string{StatusActive, StatusPastDue, StatusCanceled},
+		To:   StatusExpired,
+	},
+}
+
+// For returns the transition event makes. An unknown event applies to no
+// status.
+func For(event string) Transition {
+	return transitions[event]
+}
+
+// ChirpyRed reports whether a subscription in status gives its user Chirpy
+// Red. A canceled subscription keeps it until the period that was paid for
+// ends and it expires.
+func ChirpyRed(status string) bool {
+	return status != StatusExpired
+}
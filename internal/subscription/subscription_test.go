@@ -0,0 +1,50 @@
+package subscription
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTransitions(t *testing.T) {
+	tests := []struct {
+		name   string
+		status string
+		event  string
+		// want is the status after the event, or "" if it doesn't apply.
+		want          string
+		wantChirpyRed bool
+	}{
+		{name: "Downgrading an active subscription", status: StatusActive, event: EventDowngraded, want: StatusCanceled, wantChirpyRed: true},
+		{name: "Downgrading a past due subscription", status: StatusPastDue, event: EventDowngraded, want: StatusCanceled, wantChirpyRed: true},
+		{name: "Downgrading a canceled subscription", status: StatusCanceled, event: EventDowngraded},
+		{name: "Downgrading an expired subscription", status: StatusExpired, event: EventDowngraded},
+		{name: "Expiring an active subscription", status: StatusActive, event: EventExpired, want: StatusExpired},
+		{name: "Expiring a past due subscription", status: StatusPastDue, event: EventExpired, want: StatusExpired},
+		{name: "Expiring a canceled subscription", status: StatusCanceled, event: EventExpired, want: StatusExpired},
+		{name: "Expiring an expired subscription", status: StatusExpired, event: EventExpired},
+		{name: "Failed payment on an active subscription", status: StatusActive, event: EventPaymentFailed, want: StatusPastDue, wantChirpyRed: true},
+		{name: "Failed payment on a canceled subscription", status: StatusCanceled, event: EventPaymentFailed},
+		{name: "Upgrading after expiry", status: StatusExpired, event: EventUpgraded, want: StatusActive, wantChirpyRed: true},
+		{name: "Renewing a canceled subscription", status: StatusCanceled, event: EventRenewed, want: StatusActive, wantChirpyRed: true},
+		{name: "Unknown event", status: StatusActive, event: "user.deleted"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transition := For(tt.event)
+			applies := slices.Contains(transition.From, tt.status)
+			if applies != (tt.want != "") {
+				t.Fatalf("For(%q) applies to %q = %v, want %v", tt.event, tt.status, applies, tt.want != "")
+			}
+			if !applies {
+				return
+			}
+			if transition.To != tt.want {
+				t.Errorf("For(%q).To = %q, want %q", tt.event, transition.To, tt.want)
+			}
+			if got := ChirpyRed(transition.To); got != tt.wantChirpyRed {
+				t.Errorf("ChirpyRed(%q) = %v, want %v", transition.To, got, tt.wantChirpyRed)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package throttle
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// pruneInterval is how many failures MemoryStore records between sweeps for
+// stale keys.
+const pruneInterval = 1000
+
+// MemoryStore keeps throttling state in the process. It is lost on restart
+// and not shared between instances.
+type MemoryStore struct {
+	mu         sync.Mutex
+	states     map[string]State
+	sinceSweep int
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{states: map[string]State{}}
+}
+
+func (s *MemoryStore) RecordFailure(ctx context.Context, key string, now time.Time, staleBefore time.Time) (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sinceSweep++
+	if s.sinceSweep >= pruneInterval {
+		s.sinceSweep = 0
+		for k, state := range s.states {
+			if state.LastFailureAt.Before(staleBefore) {
+				delete(s.states, k)
+			}
+		}
+	}
+
+	state := s.states[key]
+	if state.LastFailureAt.Before(staleBefore) {
+		state = State{}
+	}
+	state.Failures++
+	state.PreviousFailureAt = state.LastFailureAt
+	state.LastFailureAt = now
+	s.states[key] = state
+	return state, nil
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.states[key], nil
+}
+
+func (s *MemoryStore) ReleaseFailure(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.states[key]
+	if !ok || state.Failures == 0 {
+		return nil
+	}
+	state.Failures--
+	if state.Failures == 0 {
+		delete(s.states, key)
+		return nil
+	}
+	if !state.PreviousFailureAt.IsZero() {
+		state.LastFailureAt = state.PreviousFailureAt
+	}
+	state.PreviousFailureAt = time.Time{}
+	s.states[key] = state
+	return nil
+}
+
+func (s *MemoryStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.states, key)
+	return nil
+}
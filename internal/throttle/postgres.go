@@ -0,0 +1,60 @@
+package throttle
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/nacen-dev/chirpy/internal/database"
+)
+
+// PostgresStore keeps throttling state in the login_throttles table, so every
+// instance sees the same failures. Recording a failure is a single upsert,
+// which keeps concurrent failures from being lost.
+type PostgresStore struct {
+	db *database.Queries
+}
+
+func NewPostgresStore(db *database.Queries) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) RecordFailure(ctx context.Context, key string, now time.Time, staleBefore time.Time) (State, error) {
+	throttle, err := s.db.RecordLoginFailure(ctx, database.RecordLoginFailureParams{
+		StaleBefore: staleBefore.UTC(),
+		Key:         key,
+		FailedAt:    now.UTC(),
+	})
+	if err != nil {
+		return State{}, err
+	}
+	return stateFromDB(throttle), nil
+}
+
+func (s *PostgresStore) Get(ctx context.Context, key string) (State, error) {
+	throttle, err := s.db.GetLoginThrottle(ctx, key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return State{}, nil
+	}
+	if err != nil {
+		return State{}, err
+	}
+	return stateFromDB(throttle), nil
+}
+
+func (s *PostgresStore) ReleaseFailure(ctx context.Context, key string) error {
+	return s.db.ReleaseLoginFailure(ctx, key)
+}
+
+func (s *PostgresStore) Reset(ctx context.Context, key string) error {
+	return s.db.DeleteLoginThrottle(ctx, key)
+}
+
+func stateFromDB(throttle database.LoginThrottle) State {
+	return State{
+		Failures:          int(throttle.Failures),
+		LastFailureAt:     throttle.LastFailureAt,
+		PreviousFailureAt: throttle.PreviousFailureAt.Time,
+	}
+}
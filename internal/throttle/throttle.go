@@ -0,0 +1,151 @@
+// Package throttle slows down repeated failures, like wrong passwords, with
+// an exponential backoff per key. State lives in a Store: MemoryStore for a
+// single instance and PostgresStore when several instances share the load.
+package throttle
+
+import (
+	"context"
+	"time"
+)
+
+// State is what a Store remembers about a key's recent failures.
+type State struct {
+	Failures      int
+	LastFailureAt time.Time
+	// PreviousFailureAt is when the failure before the last one happened. It
+	// is zero when there was none in the window.
+	PreviousFailureAt time.Time
+}
+
+type Store interface {
+	// RecordFailure counts a failure for key at now and returns the updated
+	// state. Failures from before staleBefore are forgotten first, so the
+	// count starts over after a quiet period.
+	RecordFailure(ctx context.Context, key string, now time.Time, staleBefore time.Time) (State, error)
+	// Get returns the key's state, which is the zero State for a key without
+	// failures.
+	Get(ctx context.Context, key string) (State, error)
+	// ReleaseFailure takes back the last failure of key. LastFailureAt goes
+	// back to PreviousFailureAt, which is forgotten, and a key without
+	// failures left is removed.
+	ReleaseFailure(ctx context.Context, key string) error
+	Reset(ctx context.Context, key string) error
+}
+
+// Policy decides how long a key is locked out after a number of failures.
+type Policy struct {
+	// FreeFailures is how many failures are allowed before any lockout.
+	FreeFailures int
+	// BaseDelay is the lockout after the first failure past FreeFailures. It
+	// doubles with every failure after that, up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Window is how long failures are remembered after the last one.
+	Window time.Duration
+}
+
+// Delay is how long a key with the given number of failures is locked out
+// for, counted from its last failure.
+func (p Policy) Delay(failures int) time.Duration {
+	if failures <= p.FreeFailures {
+		return 0
+	}
+	delay := p.BaseDelay
+	for i := p.FreeFailures + 1; i < failures; i++ {
+		delay *= 2
+		if delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	return min(delay, p.MaxDelay)
+}
+
+// Throttler applies a Policy to the keys of one kind, like accounts or IP
+// addresses. Its name prefixes every key, so throttlers for different kinds
+// can share a Store.
+type Throttler struct {
+	store  Store
+	name   string
+	policy Policy
+	now    func() time.Time
+}
+
+func New(store Store, name string, policy Policy) *Throttler {
+	return &Throttler{
+		store:  store,
+		name:   name,
+		policy: policy,
+		now:    time.Now,
+	}
+}
+
+func (t *Throttler) key(key string) string {
+	return t.name + ":" + key
+}
+
+// RetryAfter returns how much longer key is locked out, or 0 if it isn't.
+func (t *Throttler) RetryAfter(ctx context.Context, key string) (time.Duration, error) {
+	state, err := t.store.Get(ctx, t.key(key))
+	if err != nil {
+		return 0, err
+	}
+	return t.retryAfter(state), nil
+}
+
+// Fail records a failure for key and returns the lockout it causes, which is
+// 0 while the key is still within its free failures.
+func (t *Throttler) Fail(ctx context.Context, key string) (time.Duration, error) {
+	now := t.now()
+	state, err := t.store.RecordFailure(ctx, t.key(key), now, now.Add(-t.policy.Window))
+	if err != nil {
+		return 0, err
+	}
+	return t.retryAfter(state), nil
+}
+
+// Attempt reserves an attempt for key before it is made and returns how much
+// longer key is locked out if the attempt may not go ahead, or 0 if it may.
+// A reserved attempt counts as a failure right away, so concurrent attempts
+// can't all get in before any of them has failed; Release or Reset takes it
+// back once it has succeeded. Attempts refused while key is locked out
+// aren't counted, except when they raced with another attempt.
+func (t *Throttler) Attempt(ctx context.Context, key string) (time.Duration, error) {
+	retryAfter, err := t.RetryAfter(ctx, key)
+	if err != nil || retryAfter > 0 {
+		return retryAfter, err
+	}
+
+	now := t.now()
+	state, err := t.store.RecordFailure(ctx, t.key(key), now, now.Add(-t.policy.Window))
+	if err != nil {
+		return 0, err
+	}
+	// This attempt is failure number state.Failures. It may go ahead if the
+	// failures before it, including attempts still in flight, didn't lock
+	// the key.
+	if state.Failures <= 1 || state.PreviousFailureAt.IsZero() {
+		return 0, nil
+	}
+	lockedUntil := state.PreviousFailureAt.Add(t.policy.Delay(state.Failures - 1))
+	return max(lockedUntil.Sub(now), 0), nil
+}
+
+// Release takes back an attempt reserved with Attempt that turned out not to
+// be a failure. The lockout goes back to what the failures before it caused,
+// so a success doesn't lock the key again.
+func (t *Throttler) Release(ctx context.Context, key string) error {
+	return t.store.ReleaseFailure(ctx, t.key(key))
+}
+
+// Reset forgets key's failures, after a success or when an admin unlocks it.
+func (t *Throttler) Reset(ctx context.Context, key string) error {
+	return t.store.Reset(ctx, t.key(key))
+}
+
+func (t *Throttler) retryAfter(state State) time.Duration {
+	if state.Failures == 0 {
+		return 0
+	}
+	lockedUntil := state.LastFailureAt.Add(t.policy.Delay(state.Failures))
+	return max(lockedUntil.Sub(t.now()), 0)
+}
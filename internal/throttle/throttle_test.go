@@ -0,0 +1,260 @@
+package throttle
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPolicyDelay(t *testing.T) {
+	policy := Policy{
+		FreeFailures: 3,
+		BaseDelay:    time.Second,
+		MaxDelay:     10 * time.Second,
+	}
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: 0, want: 0},
+		{failures: 3, want: 0},
+		{failures: 4, want: time.Second},
+		{failures: 5, want: 2 * time.Second},
+		{failures: 7, want: 8 * time.Second},
+		{failures: 8, want: 10 * time.Second},
+		{failures: 1000, want: 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := policy.Delay(tt.failures); got != tt.want {
+			t.Errorf("Delay(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func newTestThrottler(now *time.Time) *Throttler {
+	throttler := New(NewMemoryStore(), "account", Policy{
+		FreeFailures: 2,
+		BaseDelay:    time.Minute,
+		MaxDelay:     time.Hour,
+		Window:       24 * time.Hour,
+	})
+	throttler.now = func() time.Time { return *now }
+	return throttler
+}
+
+func TestThrottlerLocksOutAfterFreeFailures(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	throttler := newTestThrottler(&now)
+
+	for i := 0; i < 2; i++ {
+		retryAfter, err := throttler.Fail(ctx, "user@example.com")
+		if err != nil {
+			t.Fatalf("Fail() error = %v", err)
+		}
+		if retryAfter != 0 {
+			t.Fatalf("Fail() #%d retryAfter = %v, want 0", i+1, retryAfter)
+		}
+	}
+
+	retryAfter, err := throttler.Fail(ctx, "user@example.com")
+	if err != nil {
+		t.Fatalf("Fail() error = %v", err)
+	}
+	if retryAfter != time.Minute {
+		t.Errorf("Fail() retryAfter = %v, want %v", retryAfter, time.Minute)
+	}
+
+	now = now.Add(20 * time.Second)
+	retryAfter, err = throttler.RetryAfter(ctx, "user@example.com")
+	if err != nil {
+		t.Fatalf("RetryAfter() error = %v", err)
+	}
+	if retryAfter != 40*time.Second {
+		t.Errorf("RetryAfter() = %v, want %v", retryAfter, 40*time.Second)
+	}
+
+	now = now.Add(40 * time.Second)
+	retryAfter, _ = throttler.RetryAfter(ctx, "user@example.com")
+	if retryAfter != 0 {
+		t.Errorf("RetryAfter() after the lockout = %v, want 0", retryAfter)
+	}
+
+	retryAfter, _ = throttler.Fail(ctx, "user@example.com")
+	if retryAfter != 2*time.Minute {
+		t.Errorf("Fail() after the lockout retryAfter = %v, want %v", retryAfter, 2*time.Minute)
+	}
+
+	retryAfter, _ = throttler.RetryAfter(ctx, "other@example.com")
+	if retryAfter != 0 {
+		t.Errorf("RetryAfter() for another key = %v, want 0", retryAfter)
+	}
+}
+
+func TestThrottlerReset(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	throttler := newTestThrottler(&now)
+
+	for i := 0; i < 3; i++ {
+		throttler.Fail(ctx, "user@example.com")
+	}
+	err := throttler.Reset(ctx, "user@example.com")
+	if err != nil {
+		t.Fatalf("Reset() error = %v", err)
+	}
+
+	retryAfter, _ := throttler.RetryAfter(ctx, "user@example.com")
+	if retryAfter != 0 {
+		t.Errorf("RetryAfter() after Reset() = %v, want 0", retryAfter)
+	}
+	retryAfter, _ = throttler.Fail(ctx, "user@example.com")
+	if retryAfter != 0 {
+		t.Errorf("Fail() after Reset() retryAfter = %v, want 0", retryAfter)
+	}
+}
+
+func TestThrottlerForgetsOldFailures(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	throttler := newTestThrottler(&now)
+
+	for i := 0; i < 2; i++ {
+		throttler.Fail(ctx, "user@example.com")
+	}
+
+	now = now.Add(25 * time.Hour)
+	retryAfter, _ := throttler.Fail(ctx, "user@example.com")
+	if retryAfter != 0 {
+		t.Errorf("Fail() after the window retryAfter = %v, want 0", retryAfter)
+	}
+}
+
+func TestThrottlersSharingAStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	policy := Policy{BaseDelay: time.Minute, MaxDelay: time.Hour, Window: time.Hour}
+	accounts := New(store, "account", policy)
+	ips := New(store, "ip", policy)
+
+	accounts.Fail(ctx, "198.51.100.7")
+
+	retryAfter, _ := ips.RetryAfter(ctx, "198.51.100.7")
+	if retryAfter != 0 {
+		t.Errorf("RetryAfter() for the other throttler = %v, want 0", retryAfter)
+	}
+}
+
+func TestThrottlerAttempt(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	throttler := newTestThrottler(&now)
+
+	for i := 0; i < 3; i++ {
+		retryAfter, err := throttler.Attempt(ctx, "user@example.com")
+		if err != nil {
+			t.Fatalf("Attempt() error = %v", err)
+		}
+		if retryAfter != 0 {
+			t.Fatalf("Attempt() #%d retryAfter = %v, want 0", i+1, retryAfter)
+		}
+	}
+
+	retryAfter, _ := throttler.Attempt(ctx, "user@example.com")
+	if retryAfter != time.Minute {
+		t.Errorf("Attempt() after the free failures retryAfter = %v, want %v", retryAfter, time.Minute)
+	}
+
+	// The refused attempt didn't extend the lockout.
+	now = now.Add(time.Minute)
+	retryAfter, _ = throttler.Attempt(ctx, "user@example.com")
+	if retryAfter != 0 {
+		t.Errorf("Attempt() after the lockout retryAfter = %v, want 0", retryAfter)
+	}
+}
+
+func TestThrottlerRelease(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	throttler := newTestThrottler(&now)
+
+	for i := 0; i < 10; i++ {
+		retryAfter, err := throttler.Attempt(ctx, "198.51.100.7")
+		if err != nil {
+			t.Fatalf("Attempt() error = %v", err)
+		}
+		if retryAfter != 0 {
+			t.Fatalf("Attempt() #%d retryAfter = %v, want 0", i+1, retryAfter)
+		}
+		err = throttler.Release(ctx, "198.51.100.7")
+		if err != nil {
+			t.Fatalf("Release() error = %v", err)
+		}
+	}
+}
+
+func TestThrottlerReleaseAfterExpiredLockout(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	throttler := newTestThrottler(&now)
+
+	for i := 0; i < 3; i++ {
+		_, err := throttler.Fail(ctx, "198.51.100.7")
+		if err != nil {
+			t.Fatalf("Fail() error = %v", err)
+		}
+	}
+
+	now = now.Add(time.Minute)
+	for i := 0; i < 3; i++ {
+		retryAfter, err := throttler.Attempt(ctx, "198.51.100.7")
+		if err != nil {
+			t.Fatalf("Attempt() error = %v", err)
+		}
+		if retryAfter != 0 {
+			t.Fatalf("Attempt() #%d after the lockout retryAfter = %v, want 0", i+1, retryAfter)
+		}
+		err = throttler.Release(ctx, "198.51.100.7")
+		if err != nil {
+			t.Fatalf("Release() error = %v", err)
+		}
+
+		retryAfter, err = throttler.RetryAfter(ctx, "198.51.100.7")
+		if err != nil {
+			t.Fatalf("RetryAfter() error = %v", err)
+		}
+		if retryAfter != 0 {
+			t.Errorf("RetryAfter() after success #%d = %v, want 0", i+1, retryAfter)
+		}
+		now = now.Add(time.Second)
+	}
+}
+
+func TestThrottlerConcurrentAttempts(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	throttler := newTestThrottler(&now)
+
+	var wg sync.WaitGroup
+	var allowed atomic.Int32
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			retryAfter, err := throttler.Attempt(ctx, "user@example.com")
+			if err == nil && retryAfter == 0 {
+				allowed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Two free failures plus the attempt that causes the first lockout.
+	if got := allowed.Load(); got != 3 {
+		t.Errorf("%d concurrent attempts went ahead, want 3", got)
+	}
+}
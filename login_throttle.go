@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nacen-dev/chirpy/internal/throttle"
+)
+
+// A single account gets few guesses, since a user rarely mistypes their
+// password more than a couple of times. An IP address gets more, because
+// many users can share one behind a NAT, but it stops a single client from
+// spraying guesses across accounts.
+var (
+	accountLoginPolicy = throttle.Policy{
+		FreeFailures: 5,
+		BaseDelay:    30 * time.Second,
+		MaxDelay:     15 * time.Minute,
+		Window:       time.Hour,
+	}
+	ipLoginPolicy = throttle.Policy{
+		FreeFailures: 20,
+		BaseDelay:    10 * time.Second,
+		MaxDelay:     15 * time.Minute,
+		Window:       time.Hour,
+	}
+)
+
+// loginThrottles track failed logins per account and per client IP address.
+// Accounts are keyed by the submitted email, whether or not an account has
+// it, so a lockout doesn't reveal which emails are registered.
+type loginThrottles struct {
+	accounts *throttle.Throttler
+	ips      *throttle.Throttler
+}
+
+func newLoginThrottles(store throttle.Store) loginThrottles {
+	return loginThrottles{
+		accounts: throttle.New(store, "account", accountLoginPolicy),
+		ips:      throttle.New(store, "ip", ipLoginPolicy),
+	}
+}
+
+func accountThrottleKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// reserveLoginAttempt counts a login attempt for email from ipAddress as a
+// failure before the password or code is checked, so parallel guesses can't
+// all get in before the first one has failed. It returns how much longer
+// logins are locked out, or 0 if the attempt may go ahead. An attempt that
+// succeeds is taken back with releaseLoginAttempt.
+func (cfg *apiConfig) reserveLoginAttempt(ctx context.Context, email string, ipAddress string) (time.Duration, error) {
+	accountRetryAfter, err := cfg.loginThrottles.accounts.Attempt(ctx, accountThrottleKey(email))
+	if err != nil || accountRetryAfter > 0 {
+		return accountRetryAfter, err
+	}
+	ipRetryAfter, err := cfg.loginThrottles.ips.Attempt(ctx, ipAddress)
+	if err != nil || ipRetryAfter > 0 {
+		releaseErr := cfg.loginThrottles.accounts.Release(ctx, accountThrottleKey(email))
+		if releaseErr != nil {
+			log.Printf("Unable to release login attempt for account: %s", releaseErr)
+		}
+		return ipRetryAfter, err
+	}
+	return 0, nil
+}
+
+// releaseLoginAttempt takes back an attempt reserved with
+// reserveLoginAttempt once the password or code turned out to be right.
+func (cfg *apiConfig) releaseLoginAttempt(ctx context.Context, email string, ipAddress string) {
+	err := cfg.loginThrottles.accounts.Release(ctx, accountThrottleKey(email))
+	if err != nil {
+		log.Printf("Unable to release login attempt for account: %s", err)
+	}
+	err = cfg.loginThrottles.ips.Release(ctx, ipAddress)
+	if err != nil {
+		log.Printf("Unable to release login attempt for IP address: %s", err)
+	}
+}
+
+// recordLoginFailure writes an audit entry for each lockout a wrong password
+// or second factor caused. The failure itself was already counted when the
+// attempt was reserved. userId is only set when the email belongs to an
+// account.
+func (cfg *apiConfig) recordLoginFailure(ctx context.Context, email string, ipAddress string, userId uuid.NullUUID) {
+	accountRetryAfter, err := cfg.loginThrottles.accounts.RetryAfter(ctx, accountThrottleKey(email))
+	if err != nil {
+		log.Printf("Unable to check login throttle for account: %s", err)
+	}
+	if accountRetryAfter > 0 {
+		cfg.audit(ctx, auditLoginLocked, userId, ipAddress, map[string]any{
+			"throttle":            "account",
+			"email":               email,
+			"retry_after_seconds": retryAfterSeconds(accountRetryAfter),
+		})
+	}
+
+	ipRetryAfter, err := cfg.loginThrottles.ips.RetryAfter(ctx, ipAddress)
+	if err != nil {
+		log.Printf("Unable to check login throttle for IP address: %s", err)
+	}
+	if ipRetryAfter > 0 {
+		cfg.audit(ctx, auditLoginLocked, uuid.NullUUID{}, ipAddress, map[string]any{
+			"throttle":            "ip",
+			"retry_after_seconds": retryAfterSeconds(ipRetryAfter),
+		})
+	}
+}
+
+// resetLoginThrottle forgets an account's failed logins once the user got in.
+// The IP address's failures are kept, or a client could clear them by logging
+// in to an account of its own between guesses.
+func (cfg *apiConfig) resetLoginThrottle(ctx context.Context, email string) {
+	err := cfg.loginThrottles.accounts.Reset(ctx, accountThrottleKey(email))
+	if err != nil {
+		log.Printf("Unable to reset login throttle: %s", err)
+	}
+}
+
+func retryAfterSeconds(retryAfter time.Duration) int {
+	return int(math.Ceil(retryAfter.Seconds()))
+}
+
+func respondWithLoginLocked(res http.ResponseWriter, retryAfter time.Duration) {
+	res.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(retryAfter)))
+	respondWithError(res, http.StatusTooManyRequests, "Too many failed login attempts, try again later", nil)
+}
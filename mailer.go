@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/nacen-dev/chirpy/internal/mail"
+)
+
+const mailSendTimeout = 30 * time.Second
+
+// sendMailInBackground sends msg without holding up the response. Failures
+// are only logged: the user can always ask for the mail again.
+func (cfg *apiConfig) sendMailInBackground(msg mail.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), mailSendTimeout)
+		defer cancel()
+
+		err := cfg.mailer.Send(ctx, msg)
+		if err != nil {
+			log.Printf("Unable to send %q mail: %s", msg.Subject, err)
+		}
+	}()
+}
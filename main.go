@@ -2,22 +2,42 @@ package main
 
 import (
 	"database/sql"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"sync/atomic"
+	"time"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	"github.com/nacen-dev/chirpy/internal/auth"
 	"github.com/nacen-dev/chirpy/internal/database"
+	"github.com/nacen-dev/chirpy/internal/mail"
+	"github.com/nacen-dev/chirpy/internal/passwordpolicy"
+	"github.com/nacen-dev/chirpy/internal/throttle"
 )
 
 type apiConfig struct {
-	fileserverHits atomic.Int32
-	db             *database.Queries
-	platform       string
-	jwtSecret      string
-	polkaAPIKey    string
+	fileserverHits      atomic.Int32
+	db                  *database.Queries
+	platform            string
+	jwtKeys             *auth.KeyRing
+	polkaWebhookSecrets []string
+	mailer              mail.Mailer
+	// publicURL is where clients reach the server, for links in emails.
+	publicURL string
+	// requireVerifiedEmail stops users from chirping until they verified
+	// their email address.
+	requireVerifiedEmail bool
+	loginThrottles       loginThrottles
+	passwordResetLimits  passwordResetLimits
+	// adminAPIKey authenticates the admin API. It is disabled when empty.
+	adminAPIKey    string
+	passwordHasher auth.PasswordHasher
+	passwordPolicy *passwordpolicy.Policy
 }
 
 func main() {
@@ -39,41 +59,160 @@ func main() {
 	if platform == "" {
 		log.Fatal("PLATFORM must be set")
 	}
+	// Access tokens are signed with the key ring in JWT_KEYS_DIR when there is
+	// one. JWT_SECRET then only keeps HS256 tokens issued before the switch
+	// valid and can be unset once they have expired.
+	jwtKeysDir := os.Getenv("JWT_KEYS_DIR")
 	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		log.Fatal("JWT_SECRET must be set")
+	jwtKeys := auth.NewKeyRing()
+	if jwtKeysDir != "" {
+		jwtKeys, err = auth.LoadKeyRing(jwtKeysDir, os.Getenv("JWT_SIGNING_KEY_ID"))
+		if err != nil {
+			log.Fatalf("Unable to load the JWT keys: %s", err)
+		}
+	} else if jwtSecret == "" {
+		log.Fatal("JWT_KEYS_DIR or JWT_SECRET must be set")
 	}
-	polkaAPIKey := os.Getenv("POLKA_API_KEY")
-	if polkaAPIKey == "" {
-		log.Fatal("POLKA_API_KEY must be set")
+	if jwtSecret != "" {
+		jwtKeys.SetHMACSecret(jwtSecret)
+	}
+	// POLKA_WEBHOOK_SECRETS is a comma-separated list of the secrets Polka
+	// may sign webhooks with. During a rotation it holds both the old and the
+	// new secret.
+	polkaWebhookSecrets := []string{}
+	for _, secret := range strings.Split(os.Getenv("POLKA_WEBHOOK_SECRETS"), ",") {
+		if secret = strings.TrimSpace(secret); secret != "" {
+			polkaWebhookSecrets = append(polkaWebhookSecrets, secret)
+		}
+	}
+	if len(polkaWebhookSecrets) == 0 {
+		log.Fatal("POLKA_WEBHOOK_SECRETS must be set")
+	}
+	publicURL := os.Getenv("PUBLIC_URL")
+	if publicURL == "" {
+		publicURL = "http://localhost:" + port
+	}
+	mailer, err := newMailer()
+	if err != nil {
+		log.Fatalf("Unable to set up mail: %s", err)
+	}
+	passwordHasher, err := newPasswordHasher()
+	if err != nil {
+		log.Fatalf("Unable to set up password hashing: %s", err)
+	}
+	// BREACHED_PASSWORDS_FILE is an optional list of SHA-1 hashes of breached
+	// passwords sorted by hash, like the Pwned Passwords download ordered by
+	// hash, that new passwords are checked against. It is searched on disk,
+	// so its size doesn't matter.
+	passwordPolicy := passwordpolicy.Default()
+	if breachedPasswordsFile := os.Getenv("BREACHED_PASSWORDS_FILE"); breachedPasswordsFile != "" {
+		hashFile, err := passwordpolicy.OpenSortedHashFile(breachedPasswordsFile)
+		if err != nil {
+			log.Fatalf("Unable to load the breached passwords: %s", err)
+		}
+		passwordPolicy.Breaches = &passwordpolicy.RangeChecker{Ranges: hashFile}
+	}
+	// Off by default so accounts from before email verification existed can
+	// keep chirping.
+	requireVerifiedEmail := os.Getenv("REQUIRE_VERIFIED_EMAIL") == "true"
+	// Failed logins and password reset requests are tracked in memory unless
+	// THROTTLE_STORE is "postgres", which is needed for the limits to hold
+	// across several instances.
+	var throttleStore throttle.Store = throttle.NewMemoryStore()
+	switch os.Getenv("THROTTLE_STORE") {
+	case "", "memory":
+	case "postgres":
+		throttleStore = throttle.NewPostgresStore(dbQueries)
+	default:
+		log.Fatal(`THROTTLE_STORE must be "memory" or "postgres"`)
+	}
+	subscriptionExpiryInterval := 5 * time.Minute
+	if interval := os.Getenv("SUBSCRIPTION_EXPIRY_INTERVAL"); interval != "" {
+		subscriptionExpiryInterval, err = time.ParseDuration(interval)
+		if err != nil || subscriptionExpiryInterval <= 0 {
+			log.Fatal("SUBSCRIPTION_EXPIRY_INTERVAL must be a positive duration such as 5m")
+		}
 	}
 	apiCfg := apiConfig{
-		fileserverHits: atomic.Int32{},
-		db:             dbQueries,
-		platform:       platform,
-		jwtSecret:      jwtSecret,
-		polkaAPIKey:    polkaAPIKey,
+		fileserverHits:      atomic.Int32{},
+		db:                  dbQueries,
+		platform:            platform,
+		jwtKeys:             jwtKeys,
+		polkaWebhookSecrets: polkaWebhookSecrets,
+		mailer:              mailer,
+		publicURL:           strings.TrimSuffix(publicURL, "/"),
+
+		requireVerifiedEmail: requireVerifiedEmail,
+		loginThrottles:       newLoginThrottles(throttleStore),
+		passwordResetLimits:  newPasswordResetLimits(throttleStore),
+		adminAPIKey:          os.Getenv("ADMIN_API_KEY"),
+		passwordHasher:       passwordHasher,
+		passwordPolicy:       passwordPolicy,
 	}
 
+	go apiCfg.runSubscriptionExpiry(subscriptionExpiryInterval)
+
 	serveMux := http.NewServeMux()
 	serveMux.Handle("/app/", apiCfg.middlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir(filepathRoot)))))
 
 	serveMux.HandleFunc("POST /admin/reset", apiCfg.handleResetUsers)
 	serveMux.HandleFunc("GET /admin/metrics", apiCfg.handleNumberOfRequest)
+	serveMux.HandleFunc("POST /admin/users/{userId}/unlock", apiCfg.handleUnlockUser)
+	serveMux.HandleFunc("POST /admin/users/{userId}/suspend", apiCfg.handleSuspendUser)
+	serveMux.HandleFunc("DELETE /admin/users/{userId}/suspend", apiCfg.handleUnsuspendUser)
+	serveMux.HandleFunc("GET /admin/webhooks/events", apiCfg.handleGetWebhookEvents)
+	serveMux.HandleFunc("POST /admin/webhooks/events/{eventId}/replay", apiCfg.handleReplayWebhookEvent)
+
+	serveMux.HandleFunc("GET /.well-known/jwks.json", apiCfg.handleJWKS)
 
 	serveMux.HandleFunc("GET /api/healthz", handleHealthCheck)
 	serveMux.HandleFunc("POST /api/login", apiCfg.handleLogin)
+	serveMux.HandleFunc("POST /api/login/2fa", apiCfg.handleLoginTwoFactor)
+	serveMux.HandleFunc("POST /api/password-reset/request", apiCfg.handleRequestPasswordReset)
+	serveMux.HandleFunc("POST /api/password-reset/confirm", apiCfg.handleConfirmPasswordReset)
+	serveMux.HandleFunc("GET /api/verify-email", apiCfg.handleVerifyEmail)
 	serveMux.HandleFunc("POST /api/refresh", apiCfg.handleRefresh)
 	serveMux.HandleFunc("POST /api/revoke", apiCfg.handleRevoke)
+	serveMux.HandleFunc("GET /api/sessions", apiCfg.handleGetSessions)
+	serveMux.HandleFunc("DELETE /api/sessions/{sessionId}", apiCfg.handleRevokeSession)
+	serveMux.HandleFunc("POST /api/sessions/revoke-all", apiCfg.handleRevokeAllSessions)
+	serveMux.HandleFunc("POST /api/tokens", apiCfg.handleCreatePersonalAccessToken)
+	serveMux.HandleFunc("GET /api/tokens", apiCfg.handleGetPersonalAccessTokens)
+	serveMux.HandleFunc("DELETE /api/tokens/{tokenId}", apiCfg.handleRevokePersonalAccessToken)
 	serveMux.HandleFunc("POST /api/polka/webhooks", apiCfg.handleUpgradeToChirpyRed)
+	serveMux.HandleFunc("GET /api/subscription", apiCfg.handleGetSubscription)
 
 	serveMux.HandleFunc("GET /api/chirps", apiCfg.handleGetChirps)
+	serveMux.HandleFunc("GET /api/chirps/search", apiCfg.handleSearchChirps)
 	serveMux.HandleFunc("GET /api/chirps/{chirpId}", apiCfg.handleGetChirpById)
+	serveMux.HandleFunc("GET /api/chirps/{chirpId}/thread", apiCfg.handleGetChirpThread)
+	serveMux.HandleFunc("GET /api/chirps/{chirpId}/revisions", apiCfg.handleGetChirpRevisions)
+	serveMux.HandleFunc("PATCH /api/chirps/{chirpId}", apiCfg.handleUpdateChirp)
 	serveMux.HandleFunc("DELETE /api/chirps/{chirpId}", apiCfg.handleDeleteChirpById)
 	serveMux.HandleFunc("POST /api/chirps", apiCfg.handleCreateChirp)
+	serveMux.HandleFunc("POST /api/chirps/{chirpId}/like", apiCfg.handleLikeChirp)
+	serveMux.HandleFunc("DELETE /api/chirps/{chirpId}/like", apiCfg.handleUnlikeChirp)
+	serveMux.HandleFunc("POST /api/chirps/{chirpId}/rechirp", apiCfg.handleRechirp)
+	serveMux.HandleFunc("DELETE /api/chirps/{chirpId}/rechirp", apiCfg.handleUndoRechirp)
+
+	serveMux.HandleFunc("GET /api/tags/trending", apiCfg.handleGetTrendingTags)
+	serveMux.HandleFunc("GET /api/tags/{tag}/chirps", apiCfg.handleGetTagChirps)
 
 	serveMux.HandleFunc("POST /api/users", apiCfg.handleCreateUsers)
 	serveMux.HandleFunc("PUT /api/users", apiCfg.handleUpdateUser)
+	serveMux.HandleFunc("POST /api/users/verify-email", apiCfg.handleResendEmailVerification)
+	serveMux.HandleFunc("POST /api/users/2fa/setup", apiCfg.handleSetupTwoFactor)
+	serveMux.HandleFunc("POST /api/users/2fa/confirm", apiCfg.handleConfirmTwoFactor)
+	serveMux.HandleFunc("DELETE /api/users/2fa", apiCfg.handleDisableTwoFactor)
+	serveMux.HandleFunc("GET /api/users/{userIdOrUsername}", apiCfg.handleGetUserProfile)
+	serveMux.HandleFunc("POST /api/users/{userId}/follow", apiCfg.handleFollowUser)
+	serveMux.HandleFunc("DELETE /api/users/{userId}/follow", apiCfg.handleUnfollowUser)
+	serveMux.HandleFunc("GET /api/users/{userId}/followers", apiCfg.handleGetFollowers)
+	serveMux.HandleFunc("GET /api/users/{userId}/following", apiCfg.handleGetFollowing)
+	serveMux.HandleFunc("GET /api/users/{userId}/likes", apiCfg.handleGetUserLikes)
+
+	serveMux.HandleFunc("GET /api/timeline", apiCfg.handleGetTimeline)
+	serveMux.HandleFunc("GET /api/mentions", apiCfg.handleGetMentions)
 
 	server := http.Server{
 		Addr:    ":" + port,
@@ -83,3 +222,80 @@ func main() {
 	log.Printf("Serving files from %s on port: %s\n", filepathRoot, port)
 	log.Fatal(server.ListenAndServe())
 }
+
+// newMailer sends mail through SMTP_ADDR when it is set. Otherwise mail is
+// only written to MAIL_LOG_FILE, or to stdout, which is enough for
+// development.
+func newMailer() (mail.Mailer, error) {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "Chirpy <noreply@localhost>"
+	}
+
+	if smtpAddr := os.Getenv("SMTP_ADDR"); smtpAddr != "" {
+		return &mail.SMTPMailer{
+			Addr:     smtpAddr,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}, nil
+	}
+
+	if logFile := os.Getenv("MAIL_LOG_FILE"); logFile != "" {
+		file, err := os.OpenFile(logFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+		if err != nil {
+			return nil, err
+		}
+		return &mail.LogMailer{W: file, From: from}, nil
+	}
+	return &mail.LogMailer{W: os.Stdout, From: from}, nil
+}
+
+// newPasswordHasher hashes new passwords with argon2id unless
+// PASSWORD_HASH_ALGORITHM is "bcrypt". The parameters can be tuned with
+// BCRYPT_COST or ARGON2_MEMORY_KIB, ARGON2_ITERATIONS and
+// ARGON2_PARALLELISM. Existing hashes are upgraded when their users log in.
+func newPasswordHasher() (auth.PasswordHasher, error) {
+	switch algorithm := os.Getenv("PASSWORD_HASH_ALGORITHM"); algorithm {
+	case "", "argon2id":
+		params := auth.DefaultArgon2idParams
+		memory, err := uintEnv("ARGON2_MEMORY_KIB", uint64(params.Memory), 32)
+		if err != nil {
+			return nil, err
+		}
+		iterations, err := uintEnv("ARGON2_ITERATIONS", uint64(params.Iterations), 32)
+		if err != nil {
+			return nil, err
+		}
+		parallelism, err := uintEnv("ARGON2_PARALLELISM", uint64(params.Parallelism), 8)
+		if err != nil {
+			return nil, err
+		}
+		params.Memory = uint32(memory)
+		params.Iterations = uint32(iterations)
+		params.Parallelism = uint8(parallelism)
+		return auth.NewArgon2idHasher(params)
+	case "bcrypt":
+		cost, err := uintEnv("BCRYPT_COST", 12, 8)
+		if err != nil {
+			return nil, err
+		}
+		return auth.NewBcryptHasher(int(cost))
+	default:
+		return nil, fmt.Errorf("unknown PASSWORD_HASH_ALGORITHM %q", algorithm)
+	}
+}
+
+// uintEnv reads an unsigned integer of the given bit size from the
+// environment, returning defaultValue if the variable isn't set.
+func uintEnv(name string, defaultValue uint64, bitSize int) (uint64, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	parsed, err := strconv.ParseUint(value, 10, bitSize)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return parsed, nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// pageCursor points at the last item of a page. Lists are ordered by
+// (created_at, id), so the pair is enough to resume after it. Search results
+// are ranked first, so their cursors also carry the rank.
+type pageCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+	Rank      float32
+}
+
+type pageParams struct {
+	Limit     int32
+	Cursor    pageCursor
+	HasCursor bool
+}
+
+func encodeCursor(cursor pageCursor) string {
+	raw := cursor.CreatedAt.Format(time.RFC3339Nano) + "|" + cursor.ID.String()
+	if cursor.Rank != 0 {
+		raw += "|" + strconv.FormatFloat(float64(cursor.Rank), 'g', -1, 32)
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(s string) (pageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return pageCursor{}, fmt.Errorf("invalid cursor: %w", err)
+	}
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 2 && len(parts) != 3 {
+		return pageCursor{}, errors.New("invalid cursor")
+	}
+	parsedCreatedAt, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return pageCursor{}, fmt.Errorf("invalid cursor: %w", err)
+	}
+	parsedID, err := uuid.Parse(parts[1])
+	if err != nil {
+		return pageCursor{}, fmt.Errorf("invalid cursor: %w", err)
+	}
+	cursor := pageCursor{CreatedAt: parsedCreatedAt, ID: parsedID}
+	if len(parts) == 3 {
+		rank, err := strconv.ParseFloat(parts[2], 32)
+		if err != nil {
+			return pageCursor{}, fmt.Errorf("invalid cursor: %w", err)
+		}
+		cursor.Rank = float32(rank)
+	}
+	return cursor, nil
+}
+
+func parsePageParams(req *http.Request) (pageParams, error) {
+	params := pageParams{Limit: defaultPageLimit}
+
+	if limit := req.URL.Query().Get("limit"); limit != "" {
+		parsedLimit, err := strconv.Atoi(limit)
+		if err != nil || parsedLimit < 1 {
+			return pageParams{}, errors.New("limit must be a positive integer")
+		}
+		params.Limit = int32(min(parsedLimit, maxPageLimit))
+	}
+
+	if cursor := req.URL.Query().Get("cursor"); cursor != "" {
+		parsedCursor, err := decodeCursor(cursor)
+		if err != nil {
+			return pageParams{}, err
+		}
+		params.Cursor = parsedCursor
+		params.HasCursor = true
+	}
+
+	return params, nil
+}
+
+// setNextPageLink advertises the next page through an RFC 8288 Link header,
+// keeping every other query parameter of the current request.
+func setNextPageLink(res http.ResponseWriter, req *http.Request, cursor pageCursor) {
+	query := req.URL.Query()
+	query.Set("cursor", encodeCursor(cursor))
+	next := url.URL{Path: req.URL.Path, RawQuery: query.Encode()}
+	res.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", next.String()))
+}
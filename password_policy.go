@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/nacen-dev/chirpy/internal/passwordpolicy"
+)
+
+// checkPasswordPolicy responds with every rule password breaks and returns
+// false if it isn't acceptable.
+func (cfg *apiConfig) checkPasswordPolicy(res http.ResponseWriter, password string) bool {
+	type response struct {
+		Error      string                     `json:"error"`
+		Violations []passwordpolicy.Violation `json:"violations"`
+	}
+
+	violations, err := cfg.passwordPolicy.Validate(password)
+	if err != nil {
+		respondWithError(res, http.StatusInternalServerError, "Unable to check the password", err)
+		return false
+	}
+	if len(violations) > 0 {
+		respondWithJSON(res, http.StatusBadRequest, response{
+			Error:      "Password doesn't meet the requirements",
+			Violations: violations,
+		})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nacen-dev/chirpy/internal/auth"
+)
+
+// Scopes limit what a personal access token can do. Access tokens from a
+// login carry every scope.
+const (
+	scopeChirpsRead   = "chirps:read"
+	scopeChirpsWrite  = "chirps:write"
+	scopeProfileWrite = "profile:write"
+	// scopeAccount covers managing the account's credentials, sessions and
+	// tokens. It can't be granted to a personal access token, so a leaked
+	// token can't be used to mint more tokens or lock the owner out.
+	scopeAccount = "account"
+)
+
+var grantableScopes = []string{scopeChirpsRead, scopeChirpsWrite, scopeProfileWrite}
+
+var (
+	errAccessTokenRevoked = errors.New("access token has been revoked")
+	errMissingScope       = errors.New("token lacks the scope this endpoint requires")
+)
+
+// requestAuth is who a request is authenticated as.
+type requestAuth struct {
+	UserID uuid.UUID
+	// PersonalAccessTokenID is set when the request used a personal access
+	// token rather than a login.
+	PersonalAccessTokenID uuid.NullUUID
+}
+
+// authenticate checks the request's bearer token, which is either an access
+// token from a login or a personal access token, and that it grants scope.
+// Every authenticated endpoint goes through it: for access tokens it also
+// checks that the token's version is still the user's current one, since
+// password changes and revoke-all bump it.
+func (cfg *apiConfig) authenticate(req *http.Request, scope string) (requestAuth, error) {
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		return requestAuth{}, err
+	}
+	if auth.IsPersonalAccessToken(token) {
+		return cfg.authenticatePersonalAccessToken(req, token, scope)
+	}
+
+	accessToken, err := cfg.jwtKeys.ValidateJWT(token)
+	if err != nil {
+		return requestAuth{}, err
+	}
+
+	tokenVersion, err := cfg.db.GetUserTokenVersion(req.Context(), accessToken.UserID)
+	if err != nil {
+		return requestAuth{}, err
+	}
+	if accessToken.TokenVersion != tokenVersion {
+		return requestAuth{}, errAccessTokenRevoked
+	}
+	return requestAuth{UserID: accessToken.UserID}, nil
+}
+
+func (cfg *apiConfig) authenticatePersonalAccessToken(req *http.Request, token string, scope string) (requestAuth, error) {
+	personalAccessToken, err := cfg.db.GetPersonalAccessTokenByHash(req.Context(), auth.HashToken(token))
+	if err != nil {
+		return requestAuth{}, err
+	}
+	if personalAccessToken.RevokedAt.Valid ||
+		(personalAccessToken.ExpiresAt.Valid && time.Now().After(personalAccessToken.ExpiresAt.Time)) {
+		return requestAuth{}, errAccessTokenRevoked
+	}
+	if !slices.Contains(personalAccessToken.Scopes, scope) {
+		return requestAuth{}, errMissingScope
+	}
+
+	err = cfg.db.TouchPersonalAccessToken(req.Context(), personalAccessToken.ID)
+	if err != nil {
+		log.Printf("Unable to update personal access token %s: %s", personalAccessToken.ID, err)
+	}
+	return requestAuth{
+		UserID:                personalAccessToken.UserID,
+		PersonalAccessTokenID: uuid.NullUUID{UUID: personalAccessToken.ID, Valid: true},
+	}, nil
+}
+
+// authErrorStatus is the status to respond with when authenticate fails: 403
+// for a valid token that lacks the scope, 401 for anything else.
+func authErrorStatus(err error) int {
+	if errors.Is(err, errMissingScope) {
+		return http.StatusForbidden
+	}
+	return http.StatusUnauthorized
+}
+
+// authenticatedUserID is authenticate for endpoints that only need to know
+// who the user is.
+func (cfg *apiConfig) authenticatedUserID(req *http.Request, scope string) (uuid.UUID, error) {
+	requestAuth, err := cfg.authenticate(req, scope)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return requestAuth.UserID, nil
+}
+
+// optionalUserID is authenticatedUserID for endpoints that also serve
+// anonymous readers. It returns uuid.Nil when the request carries no valid
+// token, or a personal access token without chirps:read.
+func (cfg *apiConfig) optionalUserID(req *http.Request) uuid.UUID {
+	userId, err := cfg.authenticatedUserID(req, scopeChirpsRead)
+	if err != nil {
+		return uuid.Nil
+	}
+	return userId
+}
+
+var errInvalidAdminAPIKey = errors.New("invalid or missing admin api key")
+
+// authenticateAdmin checks the request's ApiKey header against
+// ADMIN_API_KEY. The admin API is unavailable while that isn't set.
+func (cfg *apiConfig) authenticateAdmin(req *http.Request) error {
+	apiKey, err := auth.GetAPIKey(req.Header)
+	if err != nil {
+		return err
+	}
+	if !auth.APIKeyMatches(apiKey, cfg.adminAPIKey) {
+		return errInvalidAdminAPIKey
+	}
+	return nil
+}
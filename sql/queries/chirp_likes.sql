@@ -0,0 +1,33 @@
+-- name: LikeChirp :exec
+INSERT INTO chirp_likes (user_id, chirp_id, created_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT (user_id, chirp_id) DO NOTHING;
+
+-- name: UnlikeChirp :exec
+DELETE FROM chirp_likes
+WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: GetChirpLikeStats :many
+SELECT chirp_id,
+    COUNT(*) AS like_count,
+    COUNT(*) FILTER (WHERE user_id = @viewer_id) > 0 AS liked_by_viewer
+FROM chirp_likes
+WHERE chirp_id = ANY(@chirp_ids::uuid[])
+GROUP BY chirp_id;
+
+-- name: GetLikedChirps :many
+SELECT sqlc.embed(chirps), chirp_likes.created_at AS liked_at
+FROM chirp_likes
+JOIN chirps
+ON chirps.id = chirp_likes.chirp_id
+WHERE chirp_likes.user_id = @user_id
+  AND (
+    NOT @has_cursor::boolean
+    OR (chirp_likes.created_at, chirp_likes.chirp_id) < (@cursor_created_at::timestamp, @cursor_id::uuid)
+  )
+ORDER BY chirp_likes.created_at DESC, chirp_likes.chirp_id DESC
+LIMIT @page_limit;
@@ -0,0 +1,34 @@
+-- name: MentionUsers :exec
+INSERT INTO chirp_mentions (chirp_id, user_id, start_offset, end_offset)
+SELECT @chirp_id::uuid, users.id, mentions.start_offset, mentions.end_offset
+FROM unnest(@usernames::text[], @start_offsets::int[], @end_offsets::int[])
+    AS mentions(username, start_offset, end_offset)
+JOIN users
+ON LOWER(users.username) = LOWER(mentions.username)
+ON CONFLICT (chirp_id, start_offset) DO NOTHING;
+
+-- name: DeleteChirpMentions :exec
+DELETE FROM chirp_mentions
+WHERE chirp_id = $1;
+
+-- name: GetChirpMentions :many
+SELECT chirp_mentions.chirp_id, chirp_mentions.user_id, users.username, chirp_mentions.start_offset, chirp_mentions.end_offset
+FROM chirp_mentions
+JOIN users
+ON users.id = chirp_mentions.user_id
+WHERE chirp_mentions.chirp_id = ANY(@chirp_ids::uuid[])
+ORDER BY chirp_mentions.chirp_id, chirp_mentions.start_offset;
+
+-- name: GetMentionedChirps :many
+SELECT * FROM chirps
+WHERE EXISTS (
+    SELECT 1 FROM chirp_mentions
+    WHERE chirp_mentions.chirp_id = chirps.id
+      AND chirp_mentions.user_id = @user_id
+  )
+  AND (
+    NOT @has_cursor::boolean
+    OR (created_at, id) < (@cursor_created_at::timestamp, @cursor_id::uuid)
+  )
+ORDER BY created_at DESC, id DESC
+LIMIT @page_limit;
@@ -1,33 +1,166 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, in_reply_to, quote_of)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: CreateRechirp :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, rechirp_of)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    '',
+    $1,
     $2
 )
+ON CONFLICT (rechirp_of, user_id) WHERE rechirp_of IS NOT NULL DO NOTHING
 RETURNING *;
 
--- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id
-FROM chirps
-WHERE user_id = @author_id OR @author_id = '00000000-0000-0000-0000-000000000000'
-ORDER BY
-    CASE 
-        WHEN @order_by::text IS NULL 
-          OR @order_by::text = 'asc' 
-        THEN created_at 
-    END ASC,
-    CASE 
-        WHEN @order_by::text = 'desc' 
-        THEN created_at 
-    END DESC;
+-- name: GetRechirp :one
+SELECT * FROM chirps
+WHERE user_id = $1 AND rechirp_of = $2;
+
+-- name: DeleteRechirp :exec
+DELETE FROM chirps
+WHERE user_id = $1 AND rechirp_of = $2;
+
+-- name: GetChirpsAsc :many
+SELECT * FROM chirps
+WHERE (user_id = @author_id OR @author_id = '00000000-0000-0000-0000-000000000000')
+  AND (
+    NOT @has_cursor::boolean
+    OR (created_at, id) > (@cursor_created_at::timestamp, @cursor_id::uuid)
+  )
+ORDER BY created_at ASC, id ASC
+LIMIT @page_limit;
+
+-- name: GetChirpsDesc :many
+SELECT * FROM chirps
+WHERE (user_id = @author_id OR @author_id = '00000000-0000-0000-0000-000000000000')
+  AND (
+    NOT @has_cursor::boolean
+    OR (created_at, id) < (@cursor_created_at::timestamp, @cursor_id::uuid)
+  )
+ORDER BY created_at DESC, id DESC
+LIMIT @page_limit;
 
 -- name: GetChirpById :one
 SELECT * FROM chirps
 WHERE id = $1;
 
+-- name: GetChirpsByIds :many
+SELECT * FROM chirps
+WHERE id = ANY(@ids::uuid[]);
+
 -- name: DeleteChirpById :exec
+WITH tombstoned_quotes AS (
+    UPDATE chirps
+    SET quote_of = NULL, quote_deleted = true
+    WHERE quote_of = @id
+)
 DELETE FROM chirps
-WHERE id = $1;
\ No newline at end of file
+WHERE id = @id;
+
+-- name: SearchChirps :many
+SELECT sqlc.embed(chirps),
+    ts_rank(search_vector, to_tsquery('english', @query::text))::real AS rank,
+    -- The snippet is HTML, so the body is escaped before the matches are
+    -- wrapped in <mark>.
+    ts_headline(
+        'english',
+        replace(replace(replace(replace(replace(body,
+            '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;'), '''', '&#39;'),
+        to_tsquery('english', @query::text),
+        'StartSel=<mark>, StopSel=</mark>, MaxWords=20, MinWords=5'
+    ) AS snippet
+FROM chirps
+WHERE search_vector @@ to_tsquery('english', @query::text)
+  AND (user_id = @author_id OR @author_id = '00000000-0000-0000-0000-000000000000')
+  AND (
+    NOT @has_cursor::boolean
+    OR (ts_rank(search_vector, to_tsquery('english', @query::text)), created_at, id)
+      < (@cursor_rank::real, @cursor_created_at::timestamp, @cursor_id::uuid)
+  )
+ORDER BY rank DESC, created_at DESC, id DESC
+LIMIT @page_limit;
+
+-- name: GetTimeline :many
+SELECT chirps.*
+FROM chirps
+JOIN follows
+ON follows.followee_id = chirps.user_id
+WHERE follows.follower_id = @user_id
+  AND (
+    NOT @has_cursor::boolean
+    OR (chirps.created_at, chirps.id) < (@cursor_created_at::timestamp, @cursor_id::uuid)
+  )
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT @page_limit;
+
+-- name: GetChirpAncestors :many
+WITH RECURSIVE ancestors (id, in_reply_to, depth) AS (
+    SELECT parent.id, parent.in_reply_to, 1
+    FROM chirps parent
+    WHERE parent.id = (SELECT reply.in_reply_to FROM chirps reply WHERE reply.id = @chirp_id)
+    UNION ALL
+    SELECT parent.id, parent.in_reply_to, ancestors.depth + 1
+    FROM chirps parent
+    JOIN ancestors
+    ON parent.id = ancestors.in_reply_to
+)
+SELECT chirps.*
+FROM chirps
+JOIN ancestors
+ON ancestors.id = chirps.id
+ORDER BY ancestors.depth DESC;
+
+-- name: GetChirpReplies :many
+WITH RECURSIVE replies (id, depth) AS (
+    SELECT reply.id, 1
+    FROM chirps reply
+    WHERE reply.in_reply_to = @chirp_id
+    UNION ALL
+    SELECT reply.id, replies.depth + 1
+    FROM chirps reply
+    JOIN replies
+    ON reply.in_reply_to = replies.id
+    WHERE replies.depth < @max_depth::int
+)
+SELECT chirps.*
+FROM chirps
+JOIN replies
+ON replies.id = chirps.id
+ORDER BY chirps.created_at ASC, chirps.id ASC
+LIMIT @max_replies;
+
+-- name: GetReplyCounts :many
+SELECT in_reply_to::uuid AS chirp_id, COUNT(*) AS reply_count
+FROM chirps
+WHERE in_reply_to = ANY(@chirp_ids::uuid[])
+GROUP BY in_reply_to;
+
+-- name: UpdateChirpBody :one
+WITH revision AS (
+    INSERT INTO chirp_revisions (id, chirp_id, body, created_at, replaced_at)
+    SELECT gen_random_uuid(), chirps.id, chirps.body, chirps.updated_at, NOW()
+    FROM chirps
+    WHERE chirps.id = @id
+)
+UPDATE chirps
+SET body = @body, updated_at = NOW()
+WHERE id = @id
+RETURNING *;
+
+-- name: GetRechirpCounts :many
+SELECT rechirp_of::uuid AS chirp_id, COUNT(*) AS rechirp_count
+FROM chirps
+WHERE rechirp_of = ANY(@chirp_ids::uuid[])
+GROUP BY rechirp_of;
@@ -0,0 +1,37 @@
+-- name: CreateEmailVerificationToken :exec
+WITH superseded_tokens AS (
+    UPDATE email_verification_tokens
+    SET used_at = NOW()
+    WHERE user_id = @user_id
+      AND used_at IS NULL
+)
+INSERT INTO email_verification_tokens (token_hash, user_id, email, created_at, expires_at)
+VALUES (
+    @token_hash,
+    @user_id,
+    @email,
+    NOW(),
+    NOW() + make_interval(secs => @lifetime_seconds::float8)
+);
+
+-- name: VerifyEmailWithToken :one
+WITH used_token AS (
+    UPDATE email_verification_tokens
+    SET used_at = NOW()
+    WHERE token_hash = $1
+      AND used_at IS NULL
+      AND expires_at > NOW()
+    RETURNING user_id, email
+)
+UPDATE users
+SET email = used_token.email,
+    email_verified_at = NOW(),
+    pending_email = CASE WHEN users.pending_email = used_token.email THEN NULL ELSE users.pending_email END,
+    updated_at = NOW()
+FROM used_token
+WHERE users.id = used_token.user_id
+  -- Either the current email is being verified, or the change to it is
+  -- still pending. A link to an address the user has moved on from doesn't
+  -- work anymore.
+  AND (used_token.email = users.email OR used_token.email = users.pending_email)
+RETURNING users.*;
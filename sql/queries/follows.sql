@@ -0,0 +1,34 @@
+-- name: FollowUser :exec
+INSERT INTO follows (follower_id, followee_id, created_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT (follower_id, followee_id) DO NOTHING;
+
+-- name: UnfollowUser :exec
+DELETE FROM follows
+WHERE follower_id = $1 AND followee_id = $2;
+
+-- name: GetFollowers :many
+SELECT follower_id AS user_id, created_at
+FROM follows
+WHERE followee_id = @user_id
+  AND (
+    NOT @has_cursor::boolean
+    OR (created_at, follower_id) < (@cursor_created_at::timestamp, @cursor_id::uuid)
+  )
+ORDER BY created_at DESC, follower_id DESC
+LIMIT @page_limit;
+
+-- name: GetFollowing :many
+SELECT followee_id AS user_id, created_at
+FROM follows
+WHERE follower_id = @user_id
+  AND (
+    NOT @has_cursor::boolean
+    OR (created_at, followee_id) < (@cursor_created_at::timestamp, @cursor_id::uuid)
+  )
+ORDER BY created_at DESC, followee_id DESC
+LIMIT @page_limit;
@@ -0,0 +1,40 @@
+-- name: RecordLoginFailure :one
+WITH pruned_throttles AS (
+    DELETE FROM login_throttles
+    WHERE last_failure_at < @stale_before
+      AND key <> @key
+)
+INSERT INTO login_throttles (key, failures, last_failure_at, previous_failure_at)
+VALUES (@key, 1, @failed_at, NULL)
+ON CONFLICT (key) DO UPDATE
+SET failures = CASE
+        WHEN login_throttles.last_failure_at < @stale_before THEN 1
+        ELSE login_throttles.failures + 1
+    END,
+    previous_failure_at = CASE
+        WHEN login_throttles.last_failure_at < @stale_before THEN NULL
+        ELSE login_throttles.last_failure_at
+    END,
+    last_failure_at = @failed_at
+RETURNING *;
+
+-- name: GetLoginThrottle :one
+SELECT * FROM login_throttles
+WHERE key = $1;
+
+-- name: DeleteLoginThrottle :exec
+DELETE FROM login_throttles
+WHERE key = $1;
+
+-- name: ReleaseLoginFailure :exec
+WITH emptied_throttle AS (
+    DELETE FROM login_throttles
+    WHERE key = @key
+      AND failures <= 1
+)
+UPDATE login_throttles
+SET failures = failures - 1,
+    last_failure_at = COALESCE(previous_failure_at, last_failure_at),
+    previous_failure_at = NULL
+WHERE key = @key
+  AND failures > 1;
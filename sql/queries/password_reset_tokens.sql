@@ -0,0 +1,29 @@
+-- name: CreatePasswordResetToken :exec
+WITH superseded_tokens AS (
+    UPDATE password_reset_tokens
+    SET used_at = NOW()
+    WHERE user_id = @user_id
+      AND used_at IS NULL
+)
+INSERT INTO password_reset_tokens (token_hash, user_id, created_at, expires_at)
+VALUES (
+    @token_hash,
+    @user_id,
+    NOW(),
+    NOW() + make_interval(secs => @lifetime_seconds::float8)
+);
+
+-- name: ResetPasswordWithToken :one
+WITH used_token AS (
+    UPDATE password_reset_tokens
+    SET used_at = NOW()
+    WHERE token_hash = @token_hash
+      AND used_at IS NULL
+      AND expires_at > NOW()
+    RETURNING user_id
+)
+UPDATE users
+SET hashed_password = @hashed_password, updated_at = NOW()
+FROM used_token
+WHERE users.id = used_token.user_id
+RETURNING users.id;
@@ -1,12 +1,13 @@
 -- name: CreateRefreshToken :one
-INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at, revoked_at)
+INSERT INTO refresh_tokens (token_hash, created_at, updated_at, user_id, expires_at, revoked_at, family_id)
 VALUES (
     $1,
     NOW(),
     NOW(),
     $2,
     $3,
-    $4
+    $4,
+    $5
 )
 RETURNING *;
 
@@ -15,9 +16,35 @@ SELECT refresh_tokens.*, users.*
 FROM refresh_tokens
 JOIN users
 ON refresh_tokens.user_id = users.id
-WHERE refresh_tokens.token = $1;
+WHERE refresh_tokens.token_hash = $1;
 
 -- name: RevokeRefreshToken :exec
+WITH revoked_token AS (
+    UPDATE refresh_tokens
+    SET revoked_at = NOW(), updated_at = NOW()
+    WHERE token_hash = $1
+    RETURNING family_id
+)
+UPDATE sessions
+SET revoked_at = NOW()
+WHERE id IN (SELECT family_id FROM revoked_token)
+  AND revoked_at IS NULL;
+
+-- name: RevokeActiveRefreshToken :execrows
 UPDATE refresh_tokens
 SET revoked_at = NOW(), updated_at = NOW()
-WHERE token = $1;
\ No newline at end of file
+WHERE token_hash = $1
+  AND revoked_at IS NULL
+  AND expires_at > NOW();
+
+-- name: RevokeRefreshTokenFamily :exec
+WITH revoked_tokens AS (
+    UPDATE refresh_tokens
+    SET revoked_at = NOW(), updated_at = NOW()
+    WHERE family_id = $1
+      AND revoked_at IS NULL
+)
+UPDATE sessions
+SET revoked_at = NOW()
+WHERE id = $1
+  AND revoked_at IS NULL;
@@ -0,0 +1,54 @@
+-- name: CreateSession :one
+INSERT INTO sessions (id, user_id, created_at, last_used_at, user_agent, ip_address)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    NOW(),
+    NOW(),
+    $2,
+    $3
+)
+RETURNING *;
+
+-- name: TouchSession :exec
+UPDATE sessions
+SET last_used_at = NOW(), user_agent = $2, ip_address = $3
+WHERE id = $1;
+
+-- name: GetActiveSessions :many
+SELECT * FROM sessions
+WHERE user_id = $1
+  AND revoked_at IS NULL
+  AND EXISTS (
+    SELECT 1 FROM refresh_tokens
+    WHERE refresh_tokens.family_id = sessions.id
+      AND refresh_tokens.revoked_at IS NULL
+      AND refresh_tokens.expires_at > NOW()
+  )
+ORDER BY last_used_at DESC, id DESC;
+
+-- name: RevokeSession :execrows
+WITH revoked_tokens AS (
+    UPDATE refresh_tokens
+    SET revoked_at = NOW(), updated_at = NOW()
+    WHERE family_id = @id
+      AND user_id = @user_id
+      AND revoked_at IS NULL
+)
+UPDATE sessions
+SET revoked_at = NOW()
+WHERE id = @id
+  AND user_id = @user_id
+  AND revoked_at IS NULL;
+
+-- name: RevokeAllSessions :exec
+WITH revoked_tokens AS (
+    UPDATE refresh_tokens
+    SET revoked_at = NOW(), updated_at = NOW()
+    WHERE user_id = @user_id
+      AND revoked_at IS NULL
+)
+UPDATE sessions
+SET revoked_at = NOW()
+WHERE user_id = @user_id
+  AND revoked_at IS NULL;
@@ -0,0 +1,107 @@
+-- name: ActivateSubscription :one
+WITH subscription AS (
+    INSERT INTO subscriptions (id, user_id, plan, status, current_period_end, created_at, updated_at)
+    VALUES (
+        gen_random_uuid(),
+        @user_id,
+        COALESCE(sqlc.narg(plan), 'chirpy_red'),
+        @status,
+        COALESCE(sqlc.narg(current_period_end), NOW() + INTERVAL '1 month'),
+        NOW(),
+        NOW()
+    )
+    ON CONFLICT (user_id) DO UPDATE
+    SET plan = COALESCE(sqlc.narg(plan), subscriptions.plan),
+        status = @status,
+        current_period_end = COALESCE(
+            sqlc.narg(current_period_end),
+            GREATEST(subscriptions.current_period_end, NOW()) + INTERVAL '1 month'
+        ),
+        canceled_at = NULL,
+        updated_at = NOW()
+    WHERE subscriptions.status = ANY(@from_statuses::text[])
+    RETURNING id, user_id, plan, status, current_period_end, canceled_at, created_at, updated_at
+), history AS (
+    INSERT INTO subscription_events (id, subscription_id, event, status, current_period_end, webhook_event_id, created_at)
+    SELECT gen_random_uuid(), subscription.id, @event, subscription.status, subscription.current_period_end, @webhook_event_id, NOW()
+    FROM subscription
+), entitlement AS (
+    UPDATE users
+    SET is_chirpy_red = @chirpy_red, updated_at = NOW()
+    FROM subscription
+    WHERE users.id = subscription.user_id
+)
+SELECT id, user_id, plan, status, current_period_end, canceled_at, created_at, updated_at
+FROM subscription;
+
+-- name: MarkSubscriptionPastDue :one
+WITH subscription AS (
+    UPDATE subscriptions
+    SET status = @status, updated_at = NOW()
+    WHERE user_id = @user_id
+      AND status = ANY(@from_statuses::text[])
+    RETURNING id, user_id, plan, status, current_period_end, canceled_at, created_at, updated_at
+), history AS (
+    INSERT INTO subscription_events (id, subscription_id, event, status, current_period_end, webhook_event_id, created_at)
+    SELECT gen_random_uuid(), subscription.id, @event, subscription.status, subscription.current_period_end, @webhook_event_id, NOW()
+    FROM subscription
+), entitlement AS (
+    UPDATE users
+    SET is_chirpy_red = @chirpy_red, updated_at = NOW()
+    FROM subscription
+    WHERE users.id = subscription.user_id
+)
+SELECT id, user_id, plan, status, current_period_end, canceled_at, created_at, updated_at
+FROM subscription;
+
+-- name: CancelSubscription :exec
+WITH subscription AS (
+    UPDATE subscriptions
+    SET status = @status, canceled_at = NOW(), updated_at = NOW()
+    WHERE user_id = @user_id
+      AND status = ANY(@from_statuses::text[])
+    RETURNING id, status, current_period_end
+), history AS (
+    INSERT INTO subscription_events (id, subscription_id, event, status, current_period_end, webhook_event_id, created_at)
+    SELECT gen_random_uuid(), subscription.id, @event, subscription.status, subscription.current_period_end, @webhook_event_id, NOW()
+    FROM subscription
+)
+UPDATE users
+-- Chirpy Red stays until the paid period ends and the expiry job takes it
+-- away. Users who upgraded before subscriptions were tracked have nothing
+-- left to run out and lose it right away.
+SET is_chirpy_red = @chirpy_red::boolean AND EXISTS (
+        SELECT 1 FROM subscriptions
+        WHERE subscriptions.user_id = @user_id
+          AND subscriptions.current_period_end > NOW()
+    ),
+    updated_at = NOW()
+WHERE id = @user_id;
+
+-- name: ExpireLapsedSubscriptions :many
+WITH expired AS (
+    UPDATE subscriptions
+    SET status = @status, updated_at = NOW()
+    WHERE status = ANY(@from_statuses::text[])
+      AND current_period_end < NOW()
+    RETURNING id, user_id, status, current_period_end
+), history AS (
+    INSERT INTO subscription_events (id, subscription_id, event, status, current_period_end, created_at)
+    SELECT gen_random_uuid(), expired.id, @event, expired.status, expired.current_period_end, NOW()
+    FROM expired
+), entitlement AS (
+    UPDATE users
+    SET is_chirpy_red = @chirpy_red, updated_at = NOW()
+    FROM expired
+    WHERE users.id = expired.user_id
+)
+SELECT user_id FROM expired;
+
+-- name: GetSubscriptionByUserId :one
+SELECT * FROM subscriptions
+WHERE user_id = $1;
+
+-- name: GetSubscriptionEvents :many
+SELECT * FROM subscription_events
+WHERE subscription_id = $1
+ORDER BY created_at DESC, id DESC;
@@ -0,0 +1,44 @@
+-- name: TagChirp :exec
+WITH tag_names AS (
+    SELECT DISTINCT unnest(@names::text[]) AS name
+), upserted_tags AS (
+    INSERT INTO tags (id, created_at, name)
+    SELECT gen_random_uuid(), NOW(), tag_names.name
+    FROM tag_names
+    ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+    RETURNING id
+)
+INSERT INTO chirp_tags (chirp_id, tag_id, chirp_created_at)
+SELECT chirps.id, upserted_tags.id, chirps.created_at
+FROM chirps, upserted_tags
+WHERE chirps.id = @chirp_id
+ON CONFLICT (chirp_id, tag_id) DO NOTHING;
+
+-- name: DeleteChirpTags :exec
+DELETE FROM chirp_tags
+WHERE chirp_id = $1;
+
+-- name: GetTagChirps :many
+SELECT chirps.*
+FROM chirp_tags
+JOIN tags
+ON tags.id = chirp_tags.tag_id
+JOIN chirps
+ON chirps.id = chirp_tags.chirp_id
+WHERE tags.name = @tag_name
+  AND (
+    NOT @has_cursor::boolean
+    OR (chirp_tags.chirp_created_at, chirp_tags.chirp_id) < (@cursor_created_at::timestamp, @cursor_id::uuid)
+  )
+ORDER BY chirp_tags.chirp_created_at DESC, chirp_tags.chirp_id DESC
+LIMIT @page_limit;
+
+-- name: GetTrendingTags :many
+SELECT tags.name, COUNT(*) AS chirp_count
+FROM chirp_tags
+JOIN tags
+ON tags.id = chirp_tags.tag_id
+WHERE chirp_tags.chirp_created_at > NOW() - make_interval(hours => @window_hours::int)
+GROUP BY tags.name
+ORDER BY chirp_count DESC, tags.name ASC
+LIMIT @tag_limit;
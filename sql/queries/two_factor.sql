@@ -0,0 +1,42 @@
+-- name: SetPendingTOTPSecret :execrows
+UPDATE users
+SET totp_secret = @totp_secret::text, updated_at = NOW()
+WHERE id = @id
+  AND totp_enabled_at IS NULL;
+
+-- name: EnableTOTP :execrows
+UPDATE users
+SET totp_enabled_at = NOW(), totp_last_used_step = $2, updated_at = NOW()
+WHERE id = $1
+  AND totp_secret IS NOT NULL
+  AND totp_enabled_at IS NULL;
+
+-- name: DisableTOTP :exec
+WITH deleted_codes AS (
+    DELETE FROM recovery_codes
+    WHERE user_id = $1
+)
+UPDATE users
+SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_used_step = 0, updated_at = NOW()
+WHERE id = $1;
+
+-- name: UseTOTPStep :execrows
+UPDATE users
+SET totp_last_used_step = @step
+WHERE id = @id
+  AND totp_last_used_step < @step;
+
+-- name: ReplaceRecoveryCodes :exec
+WITH deleted_codes AS (
+    DELETE FROM recovery_codes
+    WHERE user_id = @user_id
+)
+INSERT INTO recovery_codes (user_id, code_hash, created_at)
+SELECT @user_id::uuid, unnest(@code_hashes::text[]), NOW();
+
+-- name: UseRecoveryCode :execrows
+UPDATE recovery_codes
+SET used_at = NOW()
+WHERE user_id = $1
+  AND code_hash = $2
+  AND used_at IS NULL;
@@ -1,11 +1,15 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, username, display_name, bio, avatar_url)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
 )
 RETURNING *;
 
@@ -18,17 +22,51 @@ WHERE email = $1;
 
 -- name: UpdateUser :one
 UPDATE users
-SET email = sqlc.arg(new_email)::text, hashed_password = sqlc.arg(new_password)::text, updated_at = NOW()
+SET pending_email = sqlc.narg(pending_email),
+    hashed_password = sqlc.arg(new_password)::text,
+    username = sqlc.narg(username),
+    display_name = sqlc.narg(display_name),
+    bio = sqlc.narg(bio),
+    avatar_url = sqlc.narg(avatar_url),
+    updated_at = NOW()
 WHERE email = sqlc.arg(old_email)::text
-RETURNING id, created_at, updated_at, email, is_chirpy_red;
+RETURNING *;
 
 -- name: GetUserById :one
 SELECT * FROM users
 WHERE id = $1;
 
--- name: UpgradeUserToChirpyRed :one
+-- name: GetUserByUsername :one
+SELECT * FROM users
+WHERE LOWER(username) = LOWER(@username::text);
+
+-- name: GetUsersByIds :many
+SELECT * FROM users
+WHERE id = ANY(@ids::uuid[]);
+
+-- name: GetUserTokenVersion :one
+SELECT token_version FROM users
+WHERE id = $1;
+
+-- name: BumpTokenVersion :exec
+UPDATE users
+SET token_version = token_version + 1, updated_at = NOW()
+WHERE id = $1;
+
+-- name: RehashPassword :exec
+UPDATE users
+SET hashed_password = @new_hash
+WHERE id = @id
+  AND hashed_password = @old_hash;
+
+-- name: SuspendUser :one
 UPDATE users
-SET is_chirpy_red = true, updated_at = NOW()
+SET suspended_at = COALESCE(suspended_at, NOW()), updated_at = NOW()
 WHERE id = $1
-RETURNING id, created_at, updated_at, email, is_chirpy_red;
+RETURNING *;
 
+-- name: UnsuspendUser :one
+UPDATE users
+SET suspended_at = NULL, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
@@ -0,0 +1,52 @@
+-- name: RecordWebhookEvent :one
+INSERT INTO webhook_events (id, provider, event_id, event_type, payload, received_at, status, attempts, started_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    NOW(),
+    'processing',
+    1,
+    NOW()
+)
+ON CONFLICT (provider, event_id) DO NOTHING
+RETURNING *;
+
+-- name: GetWebhookEventByEventId :one
+SELECT * FROM webhook_events
+WHERE provider = $1
+  AND event_id = $2;
+
+-- name: GetWebhookEventById :one
+SELECT * FROM webhook_events
+WHERE id = $1;
+
+-- name: RetryWebhookEvent :one
+UPDATE webhook_events
+SET status = 'processing', attempts = attempts + 1, started_at = NOW()
+WHERE id = $1
+  AND (
+    status = 'failed'
+    -- An event that has been processing for this long was abandoned, by a
+    -- crash for example.
+    OR (status = 'processing' AND started_at < NOW() - INTERVAL '5 minutes')
+  )
+RETURNING *;
+
+-- name: FinishWebhookEvent :one
+UPDATE webhook_events
+SET status = $2, response_status = $3, error = $4, processed_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: GetWebhookEvents :many
+SELECT * FROM webhook_events
+WHERE (sqlc.narg(status)::text IS NULL OR status = sqlc.narg(status)::text)
+  AND (
+    NOT @has_cursor::boolean
+    OR (received_at, id) < (@cursor_created_at::timestamp, @cursor_id::uuid)
+  )
+ORDER BY received_at DESC, id DESC
+LIMIT @page_limit;
@@ -0,0 +1,18 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN rechirp_of UUID REFERENCES chirps(id) ON DELETE CASCADE,
+ADD COLUMN quote_of UUID REFERENCES chirps(id) ON DELETE SET NULL,
+ADD COLUMN quote_deleted BOOLEAN NOT NULL DEFAULT false;
+
+CREATE UNIQUE INDEX chirps_rechirp_of_user_id_idx ON chirps (rechirp_of, user_id)
+WHERE rechirp_of IS NOT NULL;
+CREATE INDEX chirps_quote_of_idx ON chirps (quote_of);
+
+-- +goose Down
+DROP INDEX chirps_quote_of_idx;
+DROP INDEX chirps_rechirp_of_user_id_idx;
+
+ALTER TABLE chirps
+DROP COLUMN quote_deleted,
+DROP COLUMN quote_of,
+DROP COLUMN rechirp_of;
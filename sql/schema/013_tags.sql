@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE tags(
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  name TEXT UNIQUE NOT NULL
+);
+
+CREATE TABLE chirp_tags(
+  chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+  tag_id UUID NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+  chirp_created_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (chirp_id, tag_id)
+);
+
+CREATE INDEX chirp_tags_tag_id_chirp_created_at_idx ON chirp_tags (tag_id, chirp_created_at, chirp_id);
+CREATE INDEX chirp_tags_chirp_created_at_idx ON chirp_tags (chirp_created_at);
+
+-- +goose Down
+DROP TABLE chirp_tags;
+DROP TABLE tags;
@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE users
+ADD COLUMN username TEXT;
+
+CREATE UNIQUE INDEX users_username_key ON users (LOWER(username));
+
+-- +goose Down
+DROP INDEX users_username_key;
+
+ALTER TABLE users
+DROP COLUMN username;
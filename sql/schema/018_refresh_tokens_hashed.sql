@@ -0,0 +1,15 @@
+-- +goose Up
+-- The plaintext tokens may already have leaked, so they are invalidated
+-- rather than hashed and everyone has to log in again.
+DELETE FROM refresh_tokens;
+
+ALTER TABLE refresh_tokens
+RENAME COLUMN token TO token_hash;
+
+-- +goose Down
+-- The plaintext tokens can't be recovered from their hashes, so everyone has
+-- to log in again.
+DELETE FROM refresh_tokens;
+
+ALTER TABLE refresh_tokens
+RENAME COLUMN token_hash TO token;
@@ -0,0 +1,24 @@
+-- +goose Up
+CREATE TABLE login_throttles(
+  key TEXT PRIMARY KEY,
+  failures INTEGER NOT NULL,
+  last_failure_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX login_throttles_last_failure_at_idx ON login_throttles (last_failure_at);
+
+CREATE TABLE audit_log(
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  event TEXT NOT NULL,
+  user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+  ip_address TEXT,
+  details JSONB NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX audit_log_user_id_created_at_idx ON audit_log (user_id, created_at DESC);
+
+-- +goose Down
+DROP TABLE audit_log;
+
+DROP TABLE login_throttles;
@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE webhook_events(
+  id UUID PRIMARY KEY,
+  provider TEXT NOT NULL,
+  event_id TEXT NOT NULL,
+  event_type TEXT NOT NULL,
+  payload JSONB NOT NULL,
+  received_at TIMESTAMP NOT NULL,
+  status TEXT NOT NULL,
+  attempts INTEGER NOT NULL,
+  response_status INTEGER,
+  error TEXT,
+  processed_at TIMESTAMP,
+  UNIQUE (provider, event_id)
+);
+
+CREATE INDEX webhook_events_status_received_at_idx ON webhook_events (status, received_at DESC, id DESC);
+
+-- +goose Down
+DROP TABLE webhook_events;
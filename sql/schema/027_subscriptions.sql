@@ -0,0 +1,42 @@
+-- +goose Up
+UPDATE users
+SET is_chirpy_red = false
+WHERE is_chirpy_red IS NULL;
+
+ALTER TABLE users
+ALTER COLUMN is_chirpy_red SET NOT NULL;
+
+-- Users who upgraded before subscriptions were tracked have no row here and
+-- keep Chirpy Red until Polka sends an event for them.
+CREATE TABLE subscriptions(
+  id UUID PRIMARY KEY,
+  user_id UUID UNIQUE NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  plan TEXT NOT NULL,
+  status TEXT NOT NULL,
+  current_period_end TIMESTAMP NOT NULL,
+  canceled_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX subscriptions_status_current_period_end_idx ON subscriptions (status, current_period_end);
+
+CREATE TABLE subscription_events(
+  id UUID PRIMARY KEY,
+  subscription_id UUID NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+  event TEXT NOT NULL,
+  status TEXT NOT NULL,
+  current_period_end TIMESTAMP NOT NULL,
+  webhook_event_id UUID REFERENCES webhook_events(id) ON DELETE SET NULL,
+  created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX subscription_events_subscription_id_idx ON subscription_events (subscription_id, created_at DESC);
+
+-- +goose Down
+DROP TABLE subscription_events;
+
+DROP TABLE subscriptions;
+
+ALTER TABLE users
+ALTER COLUMN is_chirpy_red DROP NOT NULL;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- The failure before the last one, so an attempt that is counted before it
+-- is made can still be checked against the lockout the earlier failures
+-- caused.
+ALTER TABLE login_throttles
+ADD COLUMN previous_failure_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE login_throttles
+DROP COLUMN previous_failure_at;
@@ -0,0 +1,16 @@
+-- +goose Up
+-- When processing of an event last started. An event that has been
+-- processing for too long was abandoned, by a crash for example, and can be
+-- claimed again.
+ALTER TABLE webhook_events
+ADD COLUMN started_at TIMESTAMP;
+
+UPDATE webhook_events
+SET started_at = received_at;
+
+ALTER TABLE webhook_events
+ALTER COLUMN started_at SET NOT NULL;
+
+-- +goose Down
+ALTER TABLE webhook_events
+DROP COLUMN started_at;
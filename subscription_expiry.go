@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/nacen-dev/chirpy/internal/database"
+	"github.com/nacen-dev/chirpy/internal/subscription"
+)
+
+const subscriptionExpiryTimeout = time.Minute
+
+// runSubscriptionExpiry expires lapsed subscriptions every interval, taking
+// Chirpy Red away from their users. Polka doesn't tell us when a
+// subscription runs out without being renewed, or when the period a canceled
+// subscription was paid for ends, so this is the only thing that ends it.
+func (cfg *apiConfig) runSubscriptionExpiry(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		cfg.expireLapsedSubscriptions()
+		<-ticker.C
+	}
+}
+
+func (cfg *apiConfig) expireLapsedSubscriptions() {
+	ctx, cancel := context.WithTimeout(context.Background(), subscriptionExpiryTimeout)
+	defer cancel()
+
+	transition := subscription.For(subscription.EventExpired)
+	userIds, err := cfg.db.ExpireLapsedSubscriptions(ctx, database.ExpireLapsedSubscriptionsParams{
+		Status:       transition.To,
+		FromStatuses: transition.From,
+		Event:        subscription.EventExpired,
+		ChirpyRed:    subscription.ChirpyRed(transition.To),
+	})
+	if err != nil {
+		log.Printf("Unable to expire lapsed subscriptions: %s", err)
+		return
+	}
+	if len(userIds) > 0 {
+		log.Printf("Expired %d lapsed subscriptions", len(userIds))
+	}
+}